@@ -0,0 +1,245 @@
+package onthefly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// maxJSONSize is the maximum size of a JSON request body, in bytes
+const maxJSONSize = 1 << 20
+
+type (
+	// AjaxOptions configures how the JavaScript from SubmitAjax submits a form
+	AjaxOptions struct {
+		JSON   bool   // submit the values as a JSON object, instead of form-encoded
+		Target string // CSS selector for the element that gets the HTML of the response, instead of the form
+	}
+	// AjaxResponse is the JSON response that the JavaScript from SubmitAjax expects.
+	// All fields are optional. Field errors are shown next to the fields, like Form does.
+	AjaxResponse struct {
+		Redirect string            `json:"redirect,omitempty"` // URL to go to
+		HTML     string            `json:"html,omitempty"`     // HTML that replaces the form, or the contents of the target
+		Error    string            `json:"error,omitempty"`    // error message for the whole form
+		Errors   map[string]string `json:"errors,omitempty"`   // error messages, by field name
+	}
+)
+
+// ajaxSubmit is a JavaScript function that submits a form with fetch and handles the AjaxResponse
+const ajaxSubmit = `function(form, target, asJSON) {
+	var data = new FormData(form);
+	var method = (form.getAttribute("method") || "get").toUpperCase();
+	var url = form.action;
+	var options = {method: method, headers: {"Accept": "application/json"}};
+	if (method == "GET") {
+		url += (url.indexOf("?") < 0 ? "?" : "&") + new URLSearchParams(data);
+	} else if (asJSON) {
+		var values = {};
+		data.forEach(function(value, key) {
+			values[key] = key in values ? [].concat(values[key], value) : value;
+		});
+		options.headers["Content-Type"] = "application/json";
+		options.body = JSON.stringify(values);
+	} else {
+		options.body = new URLSearchParams(data);
+	}
+	fetch(url, options).then(function(r) {
+		var status = r.status + " " + r.statusText;
+		if ((r.headers.get("Content-Type") || "").indexOf("application/json") < 0) {
+			return {error: r.ok ? "The response is not JSON" : status};
+		}
+		return r.json().then(function(res) {
+			if (!r.ok && !res.error && !res.errors) {
+				res.error = status;
+			}
+			return res;
+		});
+	}).catch(function(err) {
+		return {error: String(err && err.message || err)};
+	}).then(function(res) {
+		form.querySelectorAll(".form-error, .field > .error").forEach(function(el) { el.remove(); });
+		form.querySelectorAll(".field.invalid").forEach(function(el) { el.classList.remove("invalid"); });
+		form.querySelectorAll("[aria-invalid]").forEach(function(el) { el.removeAttribute("aria-invalid"); });
+		if (res.redirect) {
+			window.location.href = res.redirect;
+			return;
+		}
+		if (res.error) {
+			var p = document.createElement("p");
+			p.className = "form-error";
+			p.textContent = res.error;
+			form.insertBefore(p, form.firstChild);
+		}
+		Object.keys(res.errors || {}).forEach(function(name) {
+			var input = form.querySelector("[name=\"" + CSS.escape(name) + "\"]");
+			if (!input) {
+				return;
+			}
+			var field = input.closest(".field") || input.parentNode;
+			field.classList.add("invalid");
+			input.setAttribute("aria-invalid", "true");
+			var span = document.createElement("span");
+			span.className = "error";
+			span.textContent = res.errors[name];
+			field.appendChild(span);
+		});
+		if (res.html) {
+			if (!target) {
+				form.outerHTML = res.html;
+			} else if (document.querySelector(target)) {
+				document.querySelector(target).innerHTML = res.html;
+			}
+		}
+	});
+}`
+
+// SubmitAjax returns a statement that makes the forms that match the given CSS selector
+// submit with fetch instead of reloading the page. The response is expected to be an
+// AjaxResponse, which can redirect, show error messages or replace the form with new HTML.
+// Network errors, error statuses and responses that are not JSON are shown as an error
+// message for the whole form, unless an error response is an AjaxResponse with errors.
+// opts may be nil. This uses plain DOM APIs, and works with both DOM backends.
+func SubmitAjax(formSelector string, opts *AjaxOptions) js.Stmt {
+	if opts == nil {
+		opts = &AjaxOptions{}
+	}
+	// The event handler is added to the document, so that it also works for forms that are replaced
+	handler := js.Func{Params: []string{"e"}, Body: []js.Stmt{
+		js.Stmt("if (!e.target.matches(" + quote(formSelector) + ")) { return; }"),
+		js.ExprStmt(js.Method(js.Ident("e"), "preventDefault")),
+		js.ExprStmt(js.Call(js.Raw("("+ajaxSubmit+")"), js.Ident("e.target"), js.String(opts.Target), js.Value(opts.JSON))),
+	}}
+	return js.ExprStmt(js.Method(js.Ident("document"), "addEventListener", js.String("submit"), handler))
+}
+
+// FetchJSON returns a statement that fetches JSON from the given URL and runs the given
+// statements, with the decoded response available as "data". If value is not nil,
+// it is sent as JSON with a POST request. If value is nil, a GET request is made.
+// If the request fails, the response has an error status or it is not JSON, "data" is null
+// and "error" is the error message. Otherwise "error" is null.
+func FetchJSON(url string, value js.Expr, body ...js.Stmt) js.Stmt {
+	options := js.Object{"headers": js.Object{"Accept": js.String("application/json")}}
+	if value != nil {
+		options = js.Object{
+			"method": js.String("POST"),
+			"headers": js.Object{
+				"Accept":       js.String("application/json"),
+				"Content-Type": js.String("application/json"),
+			},
+			"body": js.Call(js.Ident("JSON.stringify"), value),
+		}
+	}
+	r := js.Ident("r")
+	response := js.Method(js.Call(js.Ident("fetch"), js.String(url), options), "then", js.Func{
+		Params: []string{"r"},
+		Body: []js.Stmt{
+			js.If(js.Raw("!r.ok"), js.Stmt("throw new Error("+js.Concat(js.Member(r, "status"), js.String(" "), js.Member(r, "statusText")).String()+");")),
+			js.Return(js.Method(r, "json")),
+		},
+	})
+	// The result is passed on as an object, so that errors in the given statements are not
+	// caught and reported as fetch errors
+	result := js.Method(response, "then",
+		js.Func{Params: []string{"data"}, Body: []js.Stmt{js.Return(js.Object{"data": js.Ident("data"), "error": js.Raw("null")})}},
+		js.Func{Params: []string{"err"}, Body: []js.Stmt{js.Return(js.Object{"data": js.Raw("null"), "error": js.Raw("String(err && err.message || err)")})}},
+	)
+	return js.ExprStmt(js.Method(result, "then", js.Func{Params: []string{"result"}, Body: append([]js.Stmt{
+		js.Var("data", js.Member(js.Ident("result"), "data")),
+		js.Var("error", js.Member(js.Ident("result"), "error")),
+	}, body...)}))
+}
+
+// isJSON checks if the body of the given request is JSON
+func isJSON(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// DecodeJSON decodes the JSON body of the given request into v
+func DecodeJSON(req *http.Request, v any) error {
+	if !isJSON(req) {
+		return errors.New("the request does not have a JSON body")
+	}
+	return json.NewDecoder(io.LimitReader(req.Body, maxJSONSize)).Decode(v)
+}
+
+// jsonFormValue converts a value from a JSON object to form values.
+// true is converted to "on", like a checked checkbox, while false and null are left out.
+func jsonFormValue(key string, value any, values url.Values) error {
+	switch v := value.(type) {
+	case nil:
+	case bool:
+		if v {
+			values.Add(key, "on")
+		}
+	case string:
+		values.Add(key, v)
+	case json.Number:
+		values.Add(key, v.String())
+	case []any:
+		for _, element := range v {
+			if err := jsonFormValue(key, element, values); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported JSON value for %q", key)
+	}
+	return nil
+}
+
+// requestValues returns the submitted values of a request, which can be form-encoded or a JSON object
+func requestValues(req *http.Request) (url.Values, error) {
+	if !isJSON(req) {
+		if err := req.ParseForm(); err != nil {
+			return nil, err
+		}
+		return req.Form, nil
+	}
+	var object map[string]any
+	decoder := json.NewDecoder(io.LimitReader(req.Body, maxJSONSize))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	values := make(url.Values, len(object))
+	for key, value := range object {
+		if err := jsonFormValue(key, value, values); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// WriteJSON writes the given value as a JSON response, with the given status code
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	return err
+}
+
+// Write writes the response as JSON.
+// The status code is 422 (Unprocessable Entity) if there are errors, and 200 if not.
+func (resp *AjaxResponse) Write(w http.ResponseWriter) error {
+	status := http.StatusOK
+	if resp.Error != "" || len(resp.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	return WriteJSON(w, status, resp)
+}
+
+// AjaxResponse returns a response with the error messages of the form, after calling Bind
+func (form *Form) AjaxResponse() *AjaxResponse {
+	return &AjaxResponse{Error: form.formError, Errors: form.Errors()}
+}
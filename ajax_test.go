@@ -0,0 +1,101 @@
+package onthefly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+func TestBindJSON(t *testing.T) {
+	form := NewForm("/signup", "POST")
+	form.AddInput("email", "Email", "email").Required = true
+	age := form.AddInput("age", "Age", "number")
+	age.Min, age.Max = "18", "120"
+	form.AddCheckbox("terms", "I agree")
+
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(`{"email": "a@example.com", "age": 42, "terms": true}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if err := form.Bind(req); err != nil {
+		t.Fatalf("Expected the JSON values to be valid: %v %v", err, form.Errors())
+	}
+	if form.Field("age").Value != "42" || !form.Field("terms").Checked {
+		t.Errorf("Expected the JSON values to be bound, got %v", form.Values())
+	}
+
+	req = httptest.NewRequest("POST", "/signup", strings.NewReader(`{"age": 7}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := form.Bind(req); err != ErrInvalidForm {
+		t.Fatalf("Expected ErrInvalidForm, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	if err := form.AjaxResponse().Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", rec.Code)
+	}
+	var resp AjaxResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Errors["email"] == "" || resp.Errors["age"] == "" || resp.Errors["terms"] != "" {
+		t.Errorf("Expected errors for email and age, got %v", resp.Errors)
+	}
+
+	req = httptest.NewRequest("POST", "/signup", strings.NewReader(`{"email": {"nested": true}}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := form.Bind(req); err == nil || err == ErrInvalidForm {
+		t.Errorf("Expected an error for a nested object, got %v", err)
+	}
+}
+
+func TestAjaxResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := (&AjaxResponse{Redirect: "/welcome"}).Write(rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"redirect":"/welcome"}` {
+		t.Errorf("Unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected a JSON content type, got %q", ct)
+	}
+
+	var v struct{ Name string }
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"Name": "Bob"}`))
+	if err := DecodeJSON(req, &v); err == nil {
+		t.Error("Expected an error for a request without a JSON content type")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := DecodeJSON(req, &v); err != nil || v.Name != "Bob" {
+		t.Errorf("Expected the JSON body to be decoded, got %v %v", v, err)
+	}
+}
+
+func TestAjaxJS(t *testing.T) {
+	s := SubmitAjax("form.signup", &AjaxOptions{JSON: true, Target: "#result"}).String()
+	for _, expected := range []string{`e.target.matches("form.signup")`, `"#result", true)`, "fetch(url, options)", "!r.ok", "}).catch(function(err) {"} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, s)
+		}
+	}
+	if s := SubmitAjax("form", nil).String(); !strings.Contains(s, `"", false)`) {
+		t.Errorf("Expected the default options to be used:\n%s", s)
+	}
+	s = FetchJSON("/api/items", js.Object{"name": js.String("x")}, AlertStmt("saved")).String()
+	for _, expected := range []string{`fetch("/api/items", {`, `method: "POST"`, `JSON.stringify({name: "x"})`, "return r.json();",
+		`if (!r.ok) { throw new Error(r.status + " " + r.statusText); }`,
+		"function(err) { return {data: null, error: String(err && err.message || err)}; }",
+		"var data = result.data;var error = result.error;alert("} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, s)
+		}
+	}
+	if s := FetchJSON("/api/items", nil).String(); strings.Contains(s, "POST") {
+		t.Errorf("Expected a GET request:\n%s", s)
+	}
+}
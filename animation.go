@@ -0,0 +1,335 @@
+package onthefly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// keyframesJS defines the function that plays keyframe tracks. It returns a function that
+// moves the track forward by the given number of seconds and sets the interpolated value.
+const keyframesJS = `var animateKeyframes = function(set, times, values, easing, loop) {
+  var elapsed = 0, duration = times[times.length - 1];
+  var ease = {
+    linear: function(t) { return t; },
+    easeIn: function(t) { return t * t; },
+    easeOut: function(t) { return t * (2 - t); },
+    easeInOut: function(t) { return t < 0.5 ? 2 * t * t : -1 + (4 - 2 * t) * t; },
+    step: function(t) { return t < 1 ? 0 : 1; }
+  }[easing];
+  return function(delta) {
+    elapsed += delta;
+    var t = elapsed;
+    if (duration <= 0) {
+      t = 0;
+    } else if (loop === "repeat") {
+      t = t % duration;
+    } else if (loop === "pingpong") {
+      t = t % (2 * duration);
+      if (t > duration) { t = 2 * duration - t; }
+    } else {
+      t = Math.min(t, duration);
+    }
+    var i = 0;
+    while (i < times.length - 2 && t > times[i + 1]) { i++; }
+    if (times.length === 1) { set(values[0]); return; }
+    var span = times[i + 1] - times[i];
+    var f = span > 0 ? ease(Math.min(1, Math.max(0, (t - times[i]) / span))) : 1;
+    set(values[i].map(function(v, k) { return v + (values[i + 1][k] - v) * f; }));
+  };
+};`
+
+type (
+	// Animation changes objects over time. It is run by a render function at every frame,
+	// and is scaled by the time since the previous frame, so that the speed does not depend
+	// on the frame rate. See Scene.Animate and RenderFunc.AddAnimations.
+	Animation struct {
+		setup  []string // declared once, before the render function
+		update string   // run at every frame, with the seconds since the previous frame in delta
+		key    string   // animations with the same key are only run once, like the mixer of a model
+	}
+	// Keyframe is the value of a position, rotation (in radians) or scale track at the given time
+	Keyframe struct {
+		Time  float64 // seconds from the start of the track
+		Value Vector3
+	}
+	// ColorKeyframe is the color of a color track at the given time
+	ColorKeyframe struct {
+		Time  float64 // seconds from the start of the track
+		Color string  // a CSS color or a number, like "red" or 0xff0000
+	}
+	// Easing is how a keyframe track moves from one keyframe to the next
+	Easing int
+	// LoopMode is what a keyframe track or an animation clip does when it reaches the end
+	LoopMode int
+)
+
+// The easings for keyframe tracks
+const (
+	EaseLinear Easing = iota
+	EaseIn            // starts slowly
+	EaseOut           // ends slowly
+	EaseInOut         // starts and ends slowly
+	EaseStep          // jumps to the next keyframe when it is reached
+)
+
+// The loop modes for keyframe tracks and animation clips
+const (
+	LoopOnce     LoopMode = iota // stop at the last keyframe
+	LoopRepeat                   // start over from the first keyframe
+	LoopPingPong                 // go back and forth
+)
+
+// String returns the name of the easing, as used by the keyframe player
+func (e Easing) String() string {
+	switch e {
+	case EaseIn:
+		return "easeIn"
+	case EaseOut:
+		return "easeOut"
+	case EaseInOut:
+		return "easeInOut"
+	case EaseStep:
+		return "step"
+	default:
+		return "linear"
+	}
+}
+
+// String returns the name of the loop mode, as used by the keyframe player
+func (l LoopMode) String() string {
+	switch l {
+	case LoopRepeat:
+		return "repeat"
+	case LoopPingPong:
+		return "pingpong"
+	default:
+		return "once"
+	}
+}
+
+// threeLoop returns the Three.JS constant for the loop mode
+func (l LoopMode) threeLoop() js.Expr {
+	switch l {
+	case LoopOnce:
+		return threeClass("LoopOnce")
+	case LoopPingPong:
+		return threeClass("LoopPingPong")
+	default:
+		return threeClass("LoopRepeat")
+	}
+}
+
+// checkFinite returns an error if any of the given numbers are NaN or infinite
+func checkFinite(xs ...float64) error {
+	for _, x := range xs {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return errors.New("the numbers must be finite")
+		}
+	}
+	return nil
+}
+
+// Spin makes the given object rotate around its X, Y and Z axes,
+// with the given speeds in radians per second
+func Spin(obj Object, x, y, z float64) (*Animation, error) {
+	if err := checkFinite(x, y, z); err != nil {
+		return nil, err
+	}
+	id := obj.Element().ID
+	var stmts []js.Stmt
+	for i, speed := range []float64{x, y, z} {
+		if speed != 0 {
+			axis := js.Ident(id + ".rotation." + string(rune('x'+i)))
+			stmts = append(stmts, js.Assign(axis, js.Raw(axis.String()+" + "+js.Value(speed).String()+" * delta")))
+		}
+	}
+	return &Animation{update: js.Join(stmts...)}, nil
+}
+
+// Orbit makes the given object circle around the given center, in the horizontal plane,
+// with the given radius and speed in radians per second. A negative speed goes the other way.
+func Orbit(obj Object, center Vector3, radius, speed float64) (*Animation, error) {
+	if err := checkFinite(center.X, center.Y, center.Z, radius, speed); err != nil {
+		return nil, err
+	}
+	if radius < 0 {
+		return nil, errors.New("the radius can not be negative")
+	}
+	angle := js.Value(speed).String() + " * renderClock.elapsedTime"
+	x := js.Value(center.X).String() + " + " + js.Value(radius).String() + " * Math.cos(" + angle + ")"
+	z := js.Value(center.Z).String() + " + " + js.Value(radius).String() + " * Math.sin(" + angle + ")"
+	return &Animation{update: call(obj.Element().ID+".position", "set", js.Raw(x), js.Value(center.Y), js.Raw(z))}, nil
+}
+
+// Bob makes the given object move up and down around its position, by the given amplitude
+// and with the given number of bounces per second
+func Bob(obj Object, amplitude, frequency float64) (*Animation, error) {
+	if err := checkFinite(amplitude, frequency); err != nil {
+		return nil, err
+	}
+	if frequency < 0 {
+		return nil, errors.New("the frequency can not be negative")
+	}
+	id := obj.Element().ID
+	base := js.Ident(id + ".userData.bobY")
+	wave := js.Value(amplitude).String() + " * Math.sin(2 * Math.PI * " + js.Value(frequency).String() + " * renderClock.elapsedTime)"
+	return &Animation{update: js.Join(
+		js.If(js.Raw(base.String()+" === undefined"), js.Assign(base, js.Ident(id+".position.y"))),
+		js.Assign(js.Ident(id+".position.y"), js.Raw(base.String()+" + "+wave)),
+	)}, nil
+}
+
+// newTrack creates a keyframe track that calls the given setter with the interpolated values
+func (s *Scene) newTrack(setter string, times []float64, values []js.Expr, loop LoopMode, easing Easing) (*Animation, error) {
+	if len(times) == 0 {
+		return nil, errors.New("a keyframe track needs at least one keyframe")
+	}
+	if err := checkFinite(times...); err != nil {
+		return nil, err
+	}
+	for i, t := range times {
+		if t < 0 {
+			return nil, fmt.Errorf("keyframe %d has a negative time", i)
+		}
+		if i > 0 && t < times[i-1] {
+			return nil, fmt.Errorf("keyframe %d is before the previous keyframe", i)
+		}
+	}
+	id := s.nextID(animationPrefix)
+	set := js.Func{Params: []string{"v"}, Body: []js.Stmt{js.Stmt(setter)}}
+	timeExprs := make(js.Array, len(times))
+	for i, t := range times {
+		timeExprs[i] = js.Value(t)
+	}
+	track := js.Var(id, js.Call(js.Ident("animateKeyframes"), set, timeExprs, js.Array(values), js.String(easing.String()), js.String(loop.String())))
+	return &Animation{
+		setup:  []string{keyframesJS, string(track)},
+		update: js.ExprStmt(js.Call(js.Ident(id), js.Ident("delta"))).String(),
+	}, nil
+}
+
+// newVectorTrack creates a keyframe track for the position, rotation or scale of an object
+func (s *Scene) newVectorTrack(obj Object, property string, loop LoopMode, easing Easing, keyframes []Keyframe) (*Animation, error) {
+	times := make([]float64, len(keyframes))
+	values := make([]js.Expr, len(keyframes))
+	for i, k := range keyframes {
+		if err := checkFinite(k.Value.X, k.Value.Y, k.Value.Z); err != nil {
+			return nil, err
+		}
+		times[i] = k.Time
+		values[i] = js.Array(nums(k.Value.X, k.Value.Y, k.Value.Z))
+	}
+	setter := call(obj.Element().ID+"."+property, "set", js.Raw("v[0]"), js.Raw("v[1]"), js.Raw("v[2]"))
+	return s.newTrack(setter, times, values, loop, easing)
+}
+
+// NewPositionTrack creates an animation that moves the given object through the keyframes
+func (s *Scene) NewPositionTrack(obj Object, loop LoopMode, easing Easing, keyframes ...Keyframe) (*Animation, error) {
+	return s.newVectorTrack(obj, "position", loop, easing, keyframes)
+}
+
+// NewRotationTrack creates an animation that rotates the given object through the keyframes,
+// which are in radians
+func (s *Scene) NewRotationTrack(obj Object, loop LoopMode, easing Easing, keyframes ...Keyframe) (*Animation, error) {
+	return s.newVectorTrack(obj, "rotation", loop, easing, keyframes)
+}
+
+// NewScaleTrack creates an animation that scales the given object through the keyframes
+func (s *Scene) NewScaleTrack(obj Object, loop LoopMode, easing Easing, keyframes ...Keyframe) (*Animation, error) {
+	return s.newVectorTrack(obj, "scale", loop, easing, keyframes)
+}
+
+// NewColorTrack creates an animation that changes the color of the material of the given mesh
+// through the keyframes
+func (s *Scene) NewColorTrack(mesh *Mesh, loop LoopMode, easing Easing, keyframes ...ColorKeyframe) (*Animation, error) {
+	times := make([]float64, len(keyframes))
+	values := make([]js.Expr, len(keyframes))
+	for i, k := range keyframes {
+		color, err := threeColor(k.Color)
+		if err != nil {
+			return nil, err
+		}
+		times[i] = k.Time
+		values[i] = js.Method(js.New(threeClass("Color"), color), "toArray")
+	}
+	setter := call(mesh.ID+".material.color", "setRGB", js.Raw("v[0]"), js.Raw("v[1]"), js.Raw("v[2]"))
+	return s.newTrack(setter, times, values, loop, easing)
+}
+
+// Animate makes the render functions of the scene run the given animations at every frame
+func (s *Scene) Animate(animations ...*Animation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.animations = append(s.animations, animations...)
+}
+
+// NewPositionTrack creates a position keyframe track, see Scene.NewPositionTrack
+func NewPositionTrack(obj Object, loop LoopMode, easing Easing, keyframes ...Keyframe) (*Animation, error) {
+	return defaultScene.NewPositionTrack(obj, loop, easing, keyframes...)
+}
+
+// NewRotationTrack creates a rotation keyframe track, see Scene.NewRotationTrack
+func NewRotationTrack(obj Object, loop LoopMode, easing Easing, keyframes ...Keyframe) (*Animation, error) {
+	return defaultScene.NewRotationTrack(obj, loop, easing, keyframes...)
+}
+
+// NewScaleTrack creates a scale keyframe track, see Scene.NewScaleTrack
+func NewScaleTrack(obj Object, loop LoopMode, easing Easing, keyframes ...Keyframe) (*Animation, error) {
+	return defaultScene.NewScaleTrack(obj, loop, easing, keyframes...)
+}
+
+// NewColorTrack creates a color keyframe track, see Scene.NewColorTrack
+func NewColorTrack(mesh *Mesh, loop LoopMode, easing Easing, keyframes ...ColorKeyframe) (*Animation, error) {
+	return defaultScene.NewColorTrack(mesh, loop, easing, keyframes...)
+}
+
+// PlayAnimation plays the animation clip with the given name when the glTF model has been
+// loaded, or all of the clips if the name is empty. The returned animation advances the clips,
+// and must be run by the render function, see Scene.Animate.
+// The embedded loaders only keep the tracks that move, rotate or scale the nodes of the model.
+// Morph target tracks are dropped, and models with skins can not be loaded, see LoadModel.
+func (m *Model) PlayAnimation(name string, loop LoopMode) *Animation {
+	mixer := js.Ident(m.ID + ".userData.mixer")
+	clips := js.Raw("animations")
+	if name != "" {
+		clips = js.Raw("[THREE.AnimationClip.findByName(animations, " + js.String(name).String() + ")].filter(Boolean)")
+	}
+	play := []js.Stmt{
+		js.Var("action", js.Method(mixer, "clipAction", js.Ident("clip"))),
+		js.ExprStmt(js.Method(js.Ident("action"), "setLoop", loop.threeLoop(), js.Ident("Infinity"))),
+	}
+	if loop == LoopOnce {
+		play = append(play, js.Assign(js.Ident("action.clampWhenFinished"), js.Value(true)))
+	}
+	play = append(play, js.ExprStmt(js.Method(js.Ident("action"), "play")))
+	stmts := []js.Stmt{
+		js.If(js.Raw("!"+mixer.String()), js.Assign(mixer, js.New(threeClass("AnimationMixer"), js.Ident("object")))),
+		js.Var("clips", clips),
+	}
+	if name != "" {
+		stmts = append(stmts, js.If(js.Raw("clips.length === 0"),
+			js.ExprStmt(js.Call(js.Ident("console.warn"), js.String("no animation named "+name+" in "+m.ID)))))
+	}
+	stmts = append(stmts, js.ExprStmt(js.Method(js.Ident("clips"), "forEach", js.Func{Params: []string{"clip"}, Body: play})))
+	m.OnLoad(stmts...)
+	return &Animation{update: js.If(mixer, js.ExprStmt(js.Method(mixer, "update", js.Ident("delta")))).String(), key: mixer.String()}
+}
+
+// AddAnimations makes the render function run the given animations at every frame.
+// This is done automatically for the animations of a Scene, see Scene.Animate.
+// Code that is shared by several animations is only added once, and so are animations
+// that have already been added, or that advance the same animation mixer.
+func (r *RenderFunc) AddAnimations(animations ...*Animation) {
+	for _, a := range animations {
+		if slices.Contains(r.animations, a) || a.key != "" && r.markAdded(a.key) {
+			continue
+		}
+		r.animations = append(r.animations, a)
+		r.addSetup(a.setup...)
+		r.AddUpdate(js.Stmt(a.update))
+	}
+}
@@ -0,0 +1,116 @@
+package onthefly
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSceneAnimate(t *testing.T) {
+	scene := NewScene()
+	camera := scene.NewPerspectiveCamera(75, 1.5, 0.1, 1000)
+	renderer := scene.NewWebGLRenderer(true)
+	cube := scene.AddTestCube()
+	scene.AddRenderFunction(scene.NewRenderFunction(renderer, camera), true)
+	spin, err := Spin(cube, 1, 0, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	position, err := scene.NewPositionTrack(cube, LoopPingPong, EaseInOut, Keyframe{0, Vector3{}}, Keyframe{2, Vector3{2, 0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	color, err := scene.NewColorTrack(cube, LoopRepeat, EaseLinear, ColorKeyframe{0, "red"}, ColorKeyframe{1, "#0000ff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Animations that are added after the render function are also run
+	scene.Animate(spin, position, color)
+	s := scene.String()
+	expected := `var anim0 = animateKeyframes(function(v) { m0.position.set(v[0], v[1], v[2]); }, [0, 2], [[0, 0, 0], [2, 0, 0]], "easeInOut", "pingpong");` +
+		`var anim1 = animateKeyframes(function(v) { m0.material.color.setRGB(v[0], v[1], v[2]); }, [0, 1], ` +
+		`[new THREE.Color("red").toArray(), new THREE.Color("#0000ff").toArray()], "linear", "repeat");` +
+		"var render = function() { requestAnimationFrame(render);var delta = renderClock.getDelta();" +
+		"m0.rotation.x = m0.rotation.x + 1 * delta;m0.rotation.z = m0.rotation.z + 0.5 * delta;anim0(delta);anim1(delta);"
+	if !strings.Contains(s, expected) {
+		t.Errorf("Expected the animations to be run by the render function:\n%s", s)
+	}
+	if strings.Count(s, "var animateKeyframes") != 1 {
+		t.Error("Expected the keyframe player to be declared once")
+	}
+}
+
+func TestAnimationErrors(t *testing.T) {
+	scene := NewScene()
+	cube := scene.AddTestCube()
+	if _, err := scene.NewPositionTrack(cube, LoopOnce, EaseLinear); err == nil {
+		t.Error("Expected an error for a track without keyframes")
+	}
+	if _, err := scene.NewScaleTrack(cube, LoopOnce, EaseLinear, Keyframe{Time: 2}, Keyframe{Time: 1}); err == nil {
+		t.Error("Expected an error for keyframes that are out of order")
+	}
+	if _, err := scene.NewRotationTrack(cube, LoopOnce, EaseLinear, Keyframe{Time: -1}); err == nil {
+		t.Error("Expected an error for a negative time")
+	}
+	if _, err := scene.NewColorTrack(cube, LoopOnce, EaseLinear, ColorKeyframe{0, "red; alert(1)"}); err == nil {
+		t.Error("Expected an error for an invalid color")
+	}
+	if _, err := Spin(cube, math.NaN(), 0, 0); err == nil {
+		t.Error("Expected an error for NaN")
+	}
+	if _, err := Orbit(cube, Vector3{}, -1, 1); err == nil {
+		t.Error("Expected an error for a negative radius")
+	}
+	if _, err := Bob(cube, 1, -1); err == nil {
+		t.Error("Expected an error for a negative frequency")
+	}
+}
+
+func TestRenderFuncAddAnimations(t *testing.T) {
+	cube := NewScene().AddTestCube()
+	orbit, _ := Orbit(cube, Vector3{1, 2, 3}, 2, 0.5)
+	bob, _ := Bob(cube, 0.25, 1)
+	r := NewRenderFunction()
+	r.AddAnimations(orbit, bob, orbit)
+	expected := "var delta = renderClock.getDelta();" +
+		"m0.position.set(1 + 2 * Math.cos(0.5 * renderClock.elapsedTime), 2, 3 + 2 * Math.sin(0.5 * renderClock.elapsedTime));" +
+		"if (m0.userData.bobY === undefined) { m0.userData.bobY = m0.position.y; }" +
+		"m0.position.y = m0.userData.bobY + 0.25 * Math.sin(2 * Math.PI * 1 * renderClock.elapsedTime);renderer.render"
+	if s := r.String(); !strings.Contains(s, expected) {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+
+	// Animations that are equal, but were created separately, are all run
+	r = NewRenderFunction()
+	first, _ := Spin(cube, 1, 0, 0)
+	second, _ := Spin(cube, 1, 0, 0)
+	r.AddAnimations(first, second)
+	r.AddUpdate("counter++;")
+	r.AddUpdate("counter++;")
+	if s := r.String(); strings.Count(s, "m0.rotation.x = m0.rotation.x + 1 * delta;") != 2 || strings.Count(s, "counter++;") != 2 {
+		t.Errorf("Expected both spins and both updates to be run: %s", s)
+	}
+
+	// Setup code is declared even if there are no updates
+	r = NewRenderFunction()
+	r.addSetup("var helper = 1;")
+	if s := r.String(); !strings.HasPrefix(s, "var helper = 1;var render = ") {
+		t.Errorf("Expected the setup code before the render function: %s", s)
+	}
+}
+
+func TestModelPlayAnimation(t *testing.T) {
+	scene := NewScene()
+	model, _ := scene.LoadModel("/models/robot.glb")
+	walk := model.PlayAnimation("Walk", LoopOnce)
+	if !strings.Contains(model.JS, `model0.userData.mixer = new THREE.AnimationMixer(object);`) ||
+		!strings.Contains(model.JS, `THREE.AnimationClip.findByName(animations, "Walk")`) ||
+		!strings.Contains(model.JS, "action.setLoop(THREE.LoopOnce, Infinity);action.clampWhenFinished = true;action.play();") {
+		t.Errorf("Unexpected model code: %s", model.JS)
+	}
+	r := NewRenderFunction()
+	r.AddAnimations(walk, model.PlayAnimation("", LoopRepeat))
+	if s := r.String(); strings.Count(s, "model0.userData.mixer.update(delta);") != 1 {
+		t.Errorf("Expected the mixer to be updated once per frame: %s", s)
+	}
+}
@@ -0,0 +1,105 @@
+package onthefly
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// booleanPathPrefix is the prefix of the generated paths for boolean endpoints
+const booleanPathPrefix = "/onthefly/bool/"
+
+// booleanCounter is used for generating unique paths for boolean endpoints
+var booleanCounter atomic.Uint64
+
+// BooleanEndpoint is a URL that returns "1" or "0", depending on a Go function.
+// It can be used for showing or hiding tags, like ShowAnimatedIf and HideIfNot,
+// but without having to write the handler and keep the URL in sync.
+type BooleanEndpoint struct {
+	Path     string // the generated URL path
+	dom      DOMBackend
+	interval time.Duration
+}
+
+// NewBooleanEndpoint registers a handler on the given mux, under a generated path.
+// The handler returns "1" if the given function returns true for the request, and "0" if not.
+func NewBooleanEndpoint(mux *http.ServeMux, f func(*http.Request) bool) *BooleanEndpoint {
+	path := booleanPathPrefix + strconv.FormatUint(booleanCounter.Add(1), 10)
+	mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Cache-Control", "no-store")
+		if f(req) {
+			w.Write([]byte("1"))
+		} else {
+			w.Write([]byte("0"))
+		}
+	})
+	return &BooleanEndpoint{Path: path}
+}
+
+// SetDOM selects which DOM backend the generated JavaScript uses. JQueryDOM is the default.
+func (b *BooleanEndpoint) SetDOM(dom DOMBackend) {
+	b.dom = dom
+}
+
+// Poll makes the generated JavaScript check the endpoint again at the given interval,
+// so that tags are shown and hidden as the value changes. An interval of 0 disables polling.
+func (b *BooleanEndpoint) Poll(interval time.Duration) {
+	b.interval = interval
+}
+
+// backend returns the DOM backend that is used for the generated JavaScript
+func (b *BooleanEndpoint) backend() DOMBackend {
+	if b.dom == nil {
+		return JQueryDOM
+	}
+	return b.dom
+}
+
+// live returns a statement that polls the endpoint and runs onTrue or onFalse whenever the value changes
+func (b *BooleanEndpoint) live(onTrue, onFalse []js.Stmt) js.Stmt {
+	update := js.Stmt("var value = data == \"1\"; if (value !== last) { last = value; if (value) { " +
+		js.Join(onTrue...) + " } else { " + js.Join(onFalse...) + " } }")
+	poll := js.Var("poll", js.Fn(b.backend().Get(b.Path, update)))
+	interval := js.Value(b.interval.Milliseconds())
+	return js.ExprStmt(js.Call(js.Raw("(" + js.Fn(
+		js.Stmt("var last;"),
+		poll,
+		js.Stmt("poll();"),
+		js.ExprStmt(js.Call(js.Ident("setInterval"), js.Ident("poll"), interval)),
+	).String() + ")")))
+}
+
+// ShowAnimatedIf returns JavaScript that shows the given tag in an animated way if the endpoint returns "1".
+// When polling, the tag is also hidden again if the endpoint no longer returns "1".
+func (b *BooleanEndpoint) ShowAnimatedIf(tagname string) string {
+	dom := b.backend()
+	if b.interval > 0 {
+		return b.live([]js.Stmt{dom.ShowAnimated(tagname)}, []js.Stmt{dom.Hide(tagname)}).String()
+	}
+	return dom.IfURLIsTrue(b.Path, dom.ShowAnimated(tagname)).String()
+}
+
+// ShowInlineAnimatedIf returns JavaScript that shows the given tag with "display:inline" in an animated way
+// if the endpoint returns "1". When polling, the tag is also hidden again if the endpoint no longer returns "1".
+func (b *BooleanEndpoint) ShowInlineAnimatedIf(tagname string) string {
+	dom := b.backend()
+	show := []js.Stmt{dom.ShowInline(tagname), dom.Hide(tagname), dom.ShowAnimated(tagname)}
+	if b.interval > 0 {
+		return b.live(show, []js.Stmt{dom.Hide(tagname)}).String()
+	}
+	return dom.IfURLIsTrue(b.Path, show...).String()
+}
+
+// HideIfNot returns JavaScript that hides the given tag if the endpoint does not return "1".
+// When polling, the tag is also shown again if the endpoint returns "1".
+func (b *BooleanEndpoint) HideIfNot(tagname string) string {
+	dom := b.backend()
+	if b.interval > 0 {
+		return b.live([]js.Stmt{dom.Show(tagname)}, []js.Stmt{dom.Hide(tagname)}).String()
+	}
+	return dom.IfURLIsNotTrue(b.Path, dom.Hide(tagname)).String()
+}
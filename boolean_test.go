@@ -0,0 +1,52 @@
+package onthefly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBooleanEndpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	b := NewBooleanEndpoint(mux, func(req *http.Request) bool {
+		return req.URL.Query().Get("admin") == "yes"
+	})
+	other := NewBooleanEndpoint(mux, func(*http.Request) bool { return true })
+	if b.Path == other.Path {
+		t.Errorf("Expected unique paths, got %s twice", b.Path)
+	}
+	for query, expected := range map[string]string{"?admin=yes": "1", "?admin=no": "0"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", b.Path+query, nil))
+		if body := rec.Body.String(); body != expected {
+			t.Errorf("Expected %q for %s, got %q", expected, query, body)
+		}
+	}
+
+	if got, want := b.ShowAnimatedIf("#admin"), ShowAnimatedIf(b.Path, "#admin"); got != want {
+		t.Errorf("Expected the same code as ShowAnimatedIf:\n%s\n%s", want, got)
+	}
+	if got, want := b.HideIfNot("#admin"), HideIfNot(b.Path, "#admin"); got != want {
+		t.Errorf("Expected the same code as HideIfNot:\n%s\n%s", want, got)
+	}
+	if got, want := b.ShowInlineAnimatedIf("#admin"), ShowInlineAnimatedIf(b.Path, "#admin"); got != want {
+		t.Errorf("Expected the same code as ShowInlineAnimatedIf:\n%s\n%s", want, got)
+	}
+}
+
+func TestBooleanEndpointPoll(t *testing.T) {
+	b := NewBooleanEndpoint(http.NewServeMux(), func(*http.Request) bool { return false })
+	b.SetDOM(VanillaDOM)
+	b.Poll(2 * time.Second)
+	s := b.ShowAnimatedIf("#status")
+	for _, expected := range []string{"fetch(\"" + b.Path + "\")", "setInterval(poll, 2000)", "el.style.display = \"none\""} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, s)
+		}
+	}
+	if strings.Contains(s, "$(") {
+		t.Errorf("Did not expect jQuery in:\n%s", s)
+	}
+}
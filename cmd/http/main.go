@@ -2,6 +2,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -76,6 +77,9 @@ func indexPage(svgurl string) *onthefly.Page {
 
 // Set up the paths and handlers then start serving.
 func main() {
+	dev := flag.Bool("dev", false, "reload the browser when the page changes")
+	flag.Parse()
+
 	fmt.Println("onthefly", onthefly.Version)
 
 	// Create a mux
@@ -88,11 +92,20 @@ func main() {
 		w.Write(svgImage())
 	})
 
-	// Generate a Page that includes the svg image
-	page := indexPage(svgurl)
-
-	// Publish the generated Page in a way that connects the HTML and CSS
-	page.Publish(mux, "/", "/style.css", false)
+	if *dev {
+		// Render the page for every request, and reload the browser when it changes
+		reloader := onthefly.NewReloader("/reload")
+		mux.Handle("/reload", reloader)
+		reloader.Publish(mux, "/", "/style.css", func() *onthefly.Page {
+			return indexPage(svgurl)
+		})
+	} else {
+		// Generate a Page that includes the svg image
+		page := indexPage(svgurl)
+
+		// Publish the generated Page in a way that connects the HTML and CSS
+		page.Publish(mux, "/", "/style.css", false)
+	}
 
 	// Configure the HTTP server and permissionHandler struct
 	s := &http.Server{
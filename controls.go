@@ -0,0 +1,258 @@
+package onthefly
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// threeControlsID is the ID of the script tag that includes the camera controls
+const threeControlsID = "onthefly-three-controls"
+
+type (
+	// Controls represents camera controls, that let the user move the camera around.
+	// The controls must be updated at every frame, see RenderFunc.AddControls.
+	Controls Element
+	// OrbitOptions are the settings for orbit controls. Settings with the zero value are not used.
+	OrbitOptions struct {
+		Renderer    *Renderer // the renderer with the canvas that is used, or else the whole page
+		Target      Vector3   // the point that the camera orbits around
+		Damping     float64   // from 0 to 1, how quickly the movement slows down
+		AutoRotate  float64   // the speed of automatic rotation, 2 is 30 seconds per orbit
+		MinDistance float64
+		MaxDistance float64
+		DisablePan  bool
+		DisableZoom bool
+	}
+	// FlyOptions are the settings for fly controls. Settings with the zero value are not used.
+	FlyOptions struct {
+		Renderer        *Renderer // the renderer with the canvas that is used, or else the whole page
+		MovementSpeed   float64   // units per second, 1 by default
+		RollSpeed       float64   // radians per second, 0.5 by default
+		LookWithPointer bool      // look around by moving the pointer, instead of by dragging
+	}
+	// FirstPersonOptions are the settings for first-person controls.
+	// Settings with the zero value are not used.
+	FirstPersonOptions struct {
+		Renderer       *Renderer // the renderer with the canvas that is used, or else the whole page
+		MovementSpeed  float64   // units per second, 1 by default
+		LookSpeed      float64   // degrees per dragged pixel, 0.1 by default
+		NoVerticalLook bool
+		PointerLock    bool // lock the pointer on click and look by moving the mouse, until Esc is pressed
+	}
+)
+
+// UseThreeControls makes sure that the page includes the embedded camera controls.
+// They are placed after Three.JS and before the scene.
+// This is done automatically by AddScene when the scene has controls.
+func (page *Page) UseThreeControls() error {
+	script := NewTag("script")
+	script.AddContent(controlsJS)
+	return page.addThreeScript(threeControlsID, script)
+}
+
+// UseThreeControlsFrom makes sure that the page links to the camera controls at the given URL.
+// ServeThreeControls can be used for serving the embedded controls.
+func (page *Page) UseThreeControlsFrom(controlsURL string) error {
+	script := NewTag("script")
+	script.AddAttrib("src", controlsURL)
+	script.AddContent(" ")
+	return page.addThreeScript(threeControlsID, script)
+}
+
+// ServeThreeControls serves the embedded camera controls at the given URL
+func ServeThreeControls(mux *http.ServeMux, controlsURL string) {
+	mux.HandleFunc(controlsURL, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("Content-Type", "text/javascript")
+		w.Write([]byte(controlsJS))
+	})
+}
+
+// usesThreeControls checks if the given JavaScript code uses the camera controls
+func usesThreeControls(code string) bool {
+	for _, class := range []string{"OrbitControls", "FlyControls", "FirstPersonControls"} {
+		if strings.Contains(code, "THREE."+class) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSpeeds returns an error if any of the given speeds are negative
+func checkSpeeds(speeds ...float64) error {
+	for _, speed := range speeds {
+		if speed < 0 {
+			return errors.New("speeds and distances can not be negative")
+		}
+	}
+	return nil
+}
+
+// newControls creates controls of the given Three.JS class for the given camera, with the given properties
+func (s *Scene) newControls(class string, camera *Camera, renderer *Renderer, props js.Object) *Controls {
+	id := s.nextID(controlsPrefix)
+	var domElement js.Expr = js.Ident("document.body")
+	if renderer != nil {
+		domElement = js.Ident(renderer.ID + ".domElement")
+	}
+	code := newThree(id, class, js.Ident(camera.ID), domElement)
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		code += js.Assign(js.Ident(id+"."+name), props[name]).String()
+	}
+	c := &Controls{ID: id, JS: code, camera: camera.ID}
+	s.register((*Element)(c))
+	if !s.global {
+		s.mu.Lock()
+		s.controls = append(s.controls, c)
+		s.mu.Unlock()
+	}
+	return c
+}
+
+// AddOrbitControls adds controls that let the user rotate the camera around a target
+// by dragging, pan by right dragging and zoom with the mouse wheel or by pinching.
+// The controls are updated by the render functions of the scene.
+func (s *Scene) AddOrbitControls(camera *Camera, opts OrbitOptions) (*Controls, error) {
+	if err := checkSpeeds(opts.AutoRotate, opts.MinDistance, opts.MaxDistance); err != nil {
+		return nil, err
+	}
+	if opts.Damping < 0 || opts.Damping >= 1 {
+		return nil, errors.New("the damping must be from 0 and below 1")
+	}
+	if opts.MaxDistance != 0 && opts.MaxDistance < opts.MinDistance {
+		return nil, errors.New("the max distance can not be less than the min distance")
+	}
+	props := js.Object{}
+	if opts.Target != (Vector3{}) {
+		props["target"] = js.New(threeClass("Vector3"), nums(opts.Target.X, opts.Target.Y, opts.Target.Z)...)
+	}
+	if opts.Damping > 0 {
+		props["enableDamping"] = js.Value(true)
+		props["dampingFactor"] = js.Value(opts.Damping)
+	}
+	if opts.AutoRotate > 0 {
+		props["autoRotate"] = js.Value(true)
+		props["autoRotateSpeed"] = js.Value(opts.AutoRotate)
+	}
+	if opts.MinDistance > 0 {
+		props["minDistance"] = js.Value(opts.MinDistance)
+	}
+	if opts.MaxDistance > 0 {
+		props["maxDistance"] = js.Value(opts.MaxDistance)
+	}
+	if opts.DisablePan {
+		props["enablePan"] = js.Value(false)
+	}
+	if opts.DisableZoom {
+		props["enableZoom"] = js.Value(false)
+	}
+	return s.newControls("OrbitControls", camera, opts.Renderer, props), nil
+}
+
+// AddFlyControls adds controls that let the user fly the camera around with the keyboard:
+// W/S, A/D and R/F to move, Q/E to roll and the arrow keys to turn, and drag to look around.
+// The controls are updated by the render functions of the scene.
+func (s *Scene) AddFlyControls(camera *Camera, opts FlyOptions) (*Controls, error) {
+	if err := checkSpeeds(opts.MovementSpeed, opts.RollSpeed); err != nil {
+		return nil, err
+	}
+	props := js.Object{}
+	if opts.MovementSpeed > 0 {
+		props["movementSpeed"] = js.Value(opts.MovementSpeed)
+	}
+	if opts.RollSpeed > 0 {
+		props["rollSpeed"] = js.Value(opts.RollSpeed)
+	}
+	if opts.LookWithPointer {
+		props["dragToLook"] = js.Value(false)
+	}
+	return s.newControls("FlyControls", camera, opts.Renderer, props), nil
+}
+
+// AddFirstPersonControls adds controls that let the user walk around with W/S and A/D
+// or the arrow keys, and look around by dragging. With PointerLock, a click on the canvas
+// locks the pointer instead, and the user looks around by moving the mouse. The camera
+// only moves while the pointer is locked, and Esc releases it.
+// The controls are updated by the render functions of the scene.
+func (s *Scene) AddFirstPersonControls(camera *Camera, opts FirstPersonOptions) (*Controls, error) {
+	if err := checkSpeeds(opts.MovementSpeed, opts.LookSpeed); err != nil {
+		return nil, err
+	}
+	props := js.Object{}
+	if opts.MovementSpeed > 0 {
+		props["movementSpeed"] = js.Value(opts.MovementSpeed)
+	}
+	if opts.LookSpeed > 0 {
+		props["lookSpeed"] = js.Value(opts.LookSpeed)
+	}
+	if opts.NoVerticalLook {
+		props["lookVertical"] = js.Value(false)
+	}
+	if opts.PointerLock {
+		props["pointerLock"] = js.Value(true)
+	}
+	return s.newControls("FirstPersonControls", camera, opts.Renderer, props), nil
+}
+
+// Controls returns the camera controls that have been added to the scene
+func (s *Scene) Controls() []*Controls {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Controls(nil), s.controls...)
+}
+
+// AddControls makes the render function update the given controls at every frame.
+// This is done automatically for the controls of a Scene. Controls that have already
+// been added are skipped.
+func (r *RenderFunc) AddControls(controls ...*Controls) {
+	for _, c := range controls {
+		if r.markAdded(c.ID) {
+			continue
+		}
+		r.AddUpdate(js.ExprStmt(js.Method(js.Ident(c.ID), "update", js.Ident("delta"))))
+	}
+}
+
+// AddOrbitControls adds orbit controls for the given camera to a Three.JS script tag.
+// The controls must be added to the render function with RenderFunc.AddControls,
+// and the page must include the controls, see UseThreeControls.
+func (three *Tag) AddOrbitControls(camera *Camera, opts OrbitOptions) (*Controls, error) {
+	c, err := defaultScene.AddOrbitControls(camera, opts)
+	if err != nil {
+		return nil, err
+	}
+	three.AddContent(c.JS)
+	return c, nil
+}
+
+// AddFlyControls adds fly controls for the given camera to a Three.JS script tag.
+// The controls must be added to the render function with RenderFunc.AddControls,
+// and the page must include the controls, see UseThreeControls.
+func (three *Tag) AddFlyControls(camera *Camera, opts FlyOptions) (*Controls, error) {
+	c, err := defaultScene.AddFlyControls(camera, opts)
+	if err != nil {
+		return nil, err
+	}
+	three.AddContent(c.JS)
+	return c, nil
+}
+
+// AddFirstPersonControls adds first-person controls for the given camera to a Three.JS script tag.
+// The controls must be added to the render function with RenderFunc.AddControls,
+// and the page must include the controls, see UseThreeControls.
+func (three *Tag) AddFirstPersonControls(camera *Camera, opts FirstPersonOptions) (*Controls, error) {
+	c, err := defaultScene.AddFirstPersonControls(camera, opts)
+	if err != nil {
+		return nil, err
+	}
+	three.AddContent(c.JS)
+	return c, nil
+}
@@ -0,0 +1,384 @@
+// Compact camera controls for onthefly: orbit, fly and first-person controls, where the
+// first-person controls can also lock the pointer. The API is close to the controls in the
+// Three.JS examples. update(delta) must be called at every animation frame, with the time
+// since the previous frame, in seconds.
+(function(THREE) {
+	"use strict";
+
+	var EPS = 0.000001;
+
+	// listen adds event listeners, and returns a function that removes them
+	function listen(target, handlers) {
+		Object.keys(handlers).forEach(function(name) {
+			target.addEventListener(name, handlers[name]);
+		});
+		return function() {
+			Object.keys(handlers).forEach(function(name) {
+				target.removeEventListener(name, handlers[name]);
+			});
+		};
+	}
+
+	// size returns the size of the element, or of the window if the element is the document body
+	function size(el) {
+		if (el === document.body || el === document) {
+			return {width: window.innerWidth, height: window.innerHeight, left: 0, top: 0};
+		}
+		var rect = el.getBoundingClientRect();
+		return {width: rect.width, height: rect.height, left: rect.left, top: rect.top};
+	}
+
+	// Orbit controls: drag to rotate around the target, right drag (or shift drag) to pan,
+	// and the wheel or a pinch to zoom
+
+	function OrbitControls(object, domElement) {
+		this.object = object;
+		this.domElement = domElement || document.body;
+		this.target = new THREE.Vector3();
+		this.enabled = true;
+		this.enableRotate = true;
+		this.enableZoom = true;
+		this.enablePan = true;
+		this.enableDamping = false;
+		this.dampingFactor = 0.05;
+		this.autoRotate = false;
+		this.autoRotateSpeed = 2.0; // 30 seconds per orbit
+		this.rotateSpeed = 1.0;
+		this.zoomSpeed = 1.0;
+		this.minDistance = 0;
+		this.maxDistance = Infinity;
+		this.minPolarAngle = 0;
+		this.maxPolarAngle = Math.PI;
+
+		var self = this;
+		var spherical = new THREE.Spherical(), sphericalDelta = new THREE.Spherical();
+		var panOffset = new THREE.Vector3(), offset = new THREE.Vector3(), v = new THREE.Vector3();
+		var scale = 1, pointers = {}, mode = null, last = null, pinch = 0;
+
+		function pan(dx, dy) {
+			var s = size(self.domElement);
+			var distance = offset.copy(self.object.position).sub(self.target).length();
+			var x = dx, y = dy;
+			if (self.object.isPerspectiveCamera) {
+				var visible = 2 * distance * Math.tan(self.object.fov / 2 * Math.PI / 180);
+				x = dx * visible / s.height;
+				y = dy * visible / s.height;
+			} else if (self.object.isOrthographicCamera) {
+				x = dx * (self.object.right - self.object.left) / self.object.zoom / s.width;
+				y = dy * (self.object.top - self.object.bottom) / self.object.zoom / s.height;
+			}
+			self.object.updateMatrix();
+			panOffset.add(v.setFromMatrixColumn(self.object.matrix, 0).multiplyScalar(-x));
+			panOffset.add(v.setFromMatrixColumn(self.object.matrix, 1).multiplyScalar(y));
+		}
+
+		function zoom(factor) {
+			if (self.object.isOrthographicCamera) {
+				self.object.zoom = Math.max(EPS, self.object.zoom / factor);
+				self.object.updateProjectionMatrix();
+			} else {
+				scale *= factor;
+			}
+		}
+
+		function pinchDistance() {
+			var ps = Object.keys(pointers).map(function(id) {
+				return pointers[id];
+			});
+			return Math.hypot(ps[0].x - ps[1].x, ps[0].y - ps[1].y);
+		}
+
+		this.dispose = listen(this.domElement, {
+			contextmenu: function(e) {
+				if (self.enabled) {
+					e.preventDefault();
+				}
+			},
+			pointerdown: function(e) {
+				if (!self.enabled) {
+					return;
+				}
+				pointers[e.pointerId] = {x: e.clientX, y: e.clientY};
+				if (Object.keys(pointers).length == 2) {
+					mode = "pinch";
+					pinch = pinchDistance();
+				} else {
+					mode = e.button == 2 || e.shiftKey || e.ctrlKey || e.metaKey ? "pan" : "rotate";
+				}
+				last = {x: e.clientX, y: e.clientY};
+				if (self.domElement.setPointerCapture) {
+					self.domElement.setPointerCapture(e.pointerId);
+				}
+			},
+			pointermove: function(e) {
+				if (!self.enabled || !(e.pointerId in pointers)) {
+					return;
+				}
+				pointers[e.pointerId] = {x: e.clientX, y: e.clientY};
+				if (mode == "pinch") {
+					var d = pinchDistance();
+					if (self.enableZoom && d > 0 && pinch > 0) {
+						zoom(pinch / d);
+					}
+					pinch = d;
+					return;
+				}
+				var dx = e.clientX - last.x, dy = e.clientY - last.y;
+				last = {x: e.clientX, y: e.clientY};
+				if (mode == "rotate" && self.enableRotate) {
+					var h = size(self.domElement).height;
+					sphericalDelta.theta -= 2 * Math.PI * dx / h * self.rotateSpeed;
+					sphericalDelta.phi -= 2 * Math.PI * dy / h * self.rotateSpeed;
+				} else if (mode == "pan" && self.enablePan) {
+					pan(dx, dy);
+				}
+			},
+			pointerup: function(e) {
+				delete pointers[e.pointerId];
+				mode = Object.keys(pointers).length == 1 ? "rotate" : null;
+				var rest = Object.keys(pointers)[0];
+				if (rest) {
+					last = pointers[rest];
+				}
+			},
+			pointercancel: function(e) {
+				delete pointers[e.pointerId];
+				mode = null;
+			},
+			wheel: function(e) {
+				if (!self.enabled || !self.enableZoom) {
+					return;
+				}
+				e.preventDefault();
+				zoom(Math.pow(0.95, self.zoomSpeed * (e.deltaY < 0 ? 1 : -1)));
+			}
+		});
+
+		this.update = function(delta) {
+			offset.copy(this.object.position).sub(this.target);
+			spherical.setFromVector3(offset);
+			if (this.autoRotate && mode === null) {
+				sphericalDelta.theta -= 2 * Math.PI / 60 * this.autoRotateSpeed * (delta || 1 / 60);
+			}
+			var f = this.enableDamping ? this.dampingFactor : 1;
+			spherical.theta += sphericalDelta.theta * f;
+			spherical.phi += sphericalDelta.phi * f;
+			spherical.phi = Math.max(this.minPolarAngle, Math.min(this.maxPolarAngle, spherical.phi));
+			spherical.makeSafe();
+			spherical.radius = Math.max(this.minDistance, Math.min(this.maxDistance, spherical.radius * scale));
+			this.target.addScaledVector(panOffset, f);
+			offset.setFromSpherical(spherical);
+			this.object.position.copy(this.target).add(offset);
+			this.object.lookAt(this.target);
+			if (this.enableDamping) {
+				sphericalDelta.theta *= 1 - this.dampingFactor;
+				sphericalDelta.phi *= 1 - this.dampingFactor;
+				panOffset.multiplyScalar(1 - this.dampingFactor);
+			} else {
+				sphericalDelta.set(0, 0, 0);
+				panOffset.set(0, 0, 0);
+			}
+			scale = 1;
+		};
+	}
+
+	// Fly controls: W/S to move forward and back, A/D to move sideways, R/F to move up and down,
+	// Q/E to roll and the arrow keys to turn. Drag to look around, or move the pointer if
+	// dragToLook is false.
+
+	function FlyControls(object, domElement) {
+		this.object = object;
+		this.domElement = domElement || document.body;
+		this.enabled = true;
+		this.movementSpeed = 1.0;
+		this.rollSpeed = 0.5;
+		this.dragToLook = true;
+
+		var self = this;
+		var keys = {}, look = {x: 0, y: 0}, dragging = false;
+		var move = new THREE.Vector3(), rotation = new THREE.Vector3(), q = new THREE.Quaternion();
+
+		function pointer(e) {
+			var s = size(self.domElement);
+			look.x = (e.clientX - s.left - s.width / 2) / (s.width / 2);
+			look.y = (e.clientY - s.top - s.height / 2) / (s.height / 2);
+		}
+
+		var removeKeys = listen(window, {
+			keydown: function(e) {
+				if (!e.altKey && self.enabled) {
+					keys[e.code] = true;
+				}
+			},
+			keyup: function(e) {
+				delete keys[e.code];
+			},
+			blur: function() {
+				keys = {};
+			}
+		});
+		var removePointer = listen(this.domElement, {
+			contextmenu: function(e) {
+				if (self.enabled) {
+					e.preventDefault();
+				}
+			},
+			pointerdown: function(e) {
+				dragging = true;
+				pointer(e);
+			},
+			pointermove: function(e) {
+				if (!self.dragToLook || dragging) {
+					pointer(e);
+				}
+			},
+			pointerup: function() {
+				dragging = false;
+				look.x = look.y = 0;
+			},
+			pointerleave: function() {
+				if (!self.dragToLook) {
+					look.x = look.y = 0;
+				}
+			}
+		});
+		this.dispose = function() {
+			removeKeys();
+			removePointer();
+		};
+
+		function key(code) {
+			return keys[code] ? 1 : 0;
+		}
+
+		this.update = function(delta) {
+			if (!this.enabled) {
+				return;
+			}
+			delta = delta || 1 / 60;
+			move.set(key("KeyD") - key("KeyA"), key("KeyR") - key("KeyF"), key("KeyS") - key("KeyW"));
+			rotation.set(
+				key("ArrowUp") - key("ArrowDown") - look.y,
+				key("ArrowLeft") - key("ArrowRight") - look.x,
+				key("KeyQ") - key("KeyE")
+			);
+			var distance = delta * this.movementSpeed, angle = delta * this.rollSpeed;
+			this.object.translateX(move.x * distance);
+			this.object.translateY(move.y * distance);
+			this.object.translateZ(move.z * distance);
+			q.set(rotation.x * angle, rotation.y * angle, rotation.z * angle, 1).normalize();
+			this.object.quaternion.multiply(q);
+		};
+	}
+
+	// First-person controls: W/S or the up and down arrows to move forward and back,
+	// A/D or the left and right arrows to move sideways, R/F to move up and down,
+	// and drag to look around. If pointerLock is true, a click locks the pointer instead,
+	// and moving the mouse looks around until the lock is released with Esc.
+
+	function FirstPersonControls(object, domElement) {
+		this.object = object;
+		this.domElement = domElement || document.body;
+		this.enabled = true;
+		this.movementSpeed = 1.0;
+		this.lookSpeed = 0.1;
+		this.lookVertical = true;
+		this.pointerLock = false;
+		this.isLocked = false;
+
+		var self = this;
+		var keys = {}, look = {x: 0, y: 0}, dragging = false, last = null;
+		var direction = new THREE.Vector3(0, 0, -1).applyQuaternion(object.quaternion);
+		var spherical = new THREE.Spherical().setFromVector3(direction);
+		var lat = 90 - THREE.MathUtils.radToDeg(spherical.phi), lon = THREE.MathUtils.radToDeg(spherical.theta);
+		var target = new THREE.Vector3();
+
+		var removeKeys = listen(window, {
+			keydown: function(e) {
+				if (!e.altKey && self.enabled) {
+					keys[e.code] = true;
+				}
+			},
+			keyup: function(e) {
+				delete keys[e.code];
+			},
+			blur: function() {
+				keys = {};
+			}
+		});
+		var removePointer = listen(this.domElement, {
+			click: function() {
+				if (self.enabled && self.pointerLock && !self.isLocked) {
+					self.domElement.requestPointerLock();
+				}
+			},
+			pointerdown: function(e) {
+				dragging = !self.pointerLock;
+				last = {x: e.clientX, y: e.clientY};
+			},
+			pointermove: function(e) {
+				if (self.isLocked) {
+					look.x += e.movementX || 0;
+					look.y += e.movementY || 0;
+				} else if (dragging) {
+					look.x += e.clientX - last.x;
+					look.y += e.clientY - last.y;
+					last = {x: e.clientX, y: e.clientY};
+				}
+			},
+			pointerup: function() {
+				dragging = false;
+			}
+		});
+		// The browser releases the lock when Esc is pressed
+		var removeLock = listen(document, {
+			pointerlockchange: function() {
+				self.isLocked = document.pointerLockElement === self.domElement;
+				if (!self.isLocked) {
+					keys = {};
+					look.x = look.y = 0;
+				}
+			}
+		});
+		this.dispose = function() {
+			removeKeys();
+			removePointer();
+			removeLock();
+			if (this.isLocked) {
+				document.exitPointerLock();
+			}
+		};
+
+		function key() {
+			for (var i = 0; i < arguments.length; i++) {
+				if (keys[arguments[i]]) {
+					return 1;
+				}
+			}
+			return 0;
+		}
+
+		this.update = function(delta) {
+			if (!this.enabled || this.pointerLock && !this.isLocked) {
+				return;
+			}
+			delta = delta || 1 / 60;
+			var distance = delta * this.movementSpeed;
+			this.object.translateZ((key("KeyS", "ArrowDown") - key("KeyW", "ArrowUp")) * distance);
+			this.object.translateX((key("KeyD", "ArrowRight") - key("KeyA", "ArrowLeft")) * distance);
+			this.object.translateY((key("KeyR") - key("KeyF")) * distance);
+			lon -= look.x * this.lookSpeed;
+			if (this.lookVertical) {
+				lat -= look.y * this.lookSpeed;
+			}
+			look.x = look.y = 0;
+			lat = Math.max(-85, Math.min(85, lat));
+			target.setFromSphericalCoords(1, THREE.MathUtils.degToRad(90 - lat), THREE.MathUtils.degToRad(lon)).add(this.object.position);
+			this.object.lookAt(target);
+		};
+	}
+
+	THREE.OrbitControls = THREE.OrbitControls || OrbitControls;
+	THREE.FlyControls = THREE.FlyControls || FlyControls;
+	THREE.FirstPersonControls = THREE.FirstPersonControls || FirstPersonControls;
+})(THREE);
@@ -0,0 +1,150 @@
+package onthefly
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSceneControls(t *testing.T) {
+	scene := NewScene()
+	camera := scene.NewPerspectiveCamera(75, 1.5, 0.1, 1000)
+	renderer := scene.NewWebGLRenderer(true)
+	scene.AddRenderFunction(scene.NewRenderFunction(renderer, camera), true)
+	// Controls that are added after the render function are also updated
+	orbit, err := scene.AddOrbitControls(camera, OrbitOptions{Renderer: renderer, Target: Vector3{0, 1, 0}, Damping: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "var controls0 = new THREE.OrbitControls(cam0, renderer0.domElement);" +
+		"controls0.dampingFactor = 0.1;controls0.enableDamping = true;controls0.target = new THREE.Vector3(0, 1, 0);"
+	if orbit.JS != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, orbit.JS)
+	}
+	fly, _ := scene.AddFlyControls(camera, FlyOptions{MovementSpeed: 10, LookWithPointer: true})
+	if !strings.Contains(fly.JS, "new THREE.FlyControls(cam0, document.body);controls1.dragToLook = false;controls1.movementSpeed = 10;") {
+		t.Errorf("Unexpected fly controls: %s", fly.JS)
+	}
+	s := scene.String()
+	expected = "var renderClock = new THREE.Clock();var render = function() { requestAnimationFrame(render);" +
+		"var delta = renderClock.getDelta();controls0.update(delta);controls1.update(delta);renderer0.render(scene, cam0); };render();"
+	if !strings.Contains(s, expected) {
+		t.Errorf("Expected the controls to be updated by the render function:\n%s", s)
+	}
+	if len(scene.Controls()) != 2 {
+		t.Errorf("Expected 2 controls, got %d", len(scene.Controls()))
+	}
+
+	page, _ := NewThreeJS()
+	page.AddScene(scene)
+	if html := page.String(); strings.Count(html, `id="onthefly-three-controls"`) != 1 || strings.Contains(html, "onthefly-three-loaders") {
+		t.Error("Expected the controls, but not the model loaders, to be included")
+	}
+
+	for _, opts := range []OrbitOptions{{Damping: 1}, {AutoRotate: -1}, {MinDistance: 5, MaxDistance: 2}} {
+		if _, err := scene.AddOrbitControls(camera, opts); err == nil {
+			t.Errorf("Expected an error for %+v", opts)
+		}
+	}
+	if _, err := scene.AddFirstPersonControls(camera, FirstPersonOptions{LookSpeed: -1}); err == nil {
+		t.Error("Expected an error for a negative look speed")
+	}
+}
+
+func TestSceneControlsCamera(t *testing.T) {
+	scene := NewScene()
+	camera := scene.NewPerspectiveCamera(75, 1.5, 0.1, 1000)
+	other := scene.NewOrthographicCamera(-1, 1, 1, -1, 0.1, 100)
+	renderer := scene.NewWebGLRenderer(true)
+	scene.AddRenderFunction(scene.NewRenderFunction(renderer, camera), true)
+	if _, err := scene.AddOrbitControls(other, OrbitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// Controls for a camera that is not rendered are not updated
+	if s := scene.String(); strings.Contains(s, "controls0.update(delta);") {
+		t.Errorf("Expected only the controls of the rendered camera to be updated:\n%s", s)
+	}
+}
+
+func TestRenderFuncAddControls(t *testing.T) {
+	_, three := NewThreeJS()
+	camera := three.AddCamera()
+	controls, err := three.AddFirstPersonControls(camera, FirstPersonOptions{NoVerticalLook: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRenderFunction()
+	r.AddControls(controls, controls)
+	r.AddJS("cube.rotation.x += delta;")
+	s := r.String()
+	if strings.Count(s, controls.ID+".update(delta);") != 1 || !strings.Contains(s, "update(delta);cube.rotation.x += delta;") {
+		t.Errorf("Expected the controls to be updated once, before the rest:\n%s", s)
+	}
+	if !strings.Contains(three.String(), "new THREE.FirstPersonControls(camera, document.body);"+controls.ID+".lookVertical = false;") {
+		t.Errorf("Unexpected script: %s", three.String())
+	}
+}
+
+// pointerLockTestJS runs first-person controls with pointer lock on a fake document,
+// and prints the direction of the camera after each step
+const pointerLockTestJS = `global.window = new EventTarget();
+global.document = new EventTarget();
+var canvas = new EventTarget();
+canvas.requestPointerLock = function() {
+	document.pointerLockElement = canvas;
+	document.dispatchEvent(new Event("pointerlockchange"));
+};
+var move = function(x, y) {
+	canvas.dispatchEvent(Object.assign(new Event("pointermove"), {movementX: x, movementY: y, clientX: 0, clientY: 0}));
+};
+var camera = new THREE.PerspectiveCamera();
+var controls = new THREE.FirstPersonControls(camera, canvas);
+controls.pointerLock = true;
+var steps = [];
+var step = function(name) {
+	controls.update(0.1);
+	var d = camera.getWorldDirection(new THREE.Vector3());
+	steps.push({name: name, locked: controls.isLocked, x: Math.round(d.x * 1000) / 1000, z: Math.round(d.z * 1000) / 1000});
+};
+move(300, 0);
+step("unlocked");
+canvas.dispatchEvent(new Event("click"));
+move(300, 0);
+step("locked");
+document.pointerLockElement = null;
+document.dispatchEvent(new Event("pointerlockchange"));
+move(300, 0);
+step("released");
+console.log(JSON.stringify(steps));`
+
+func TestPointerLockControls(t *testing.T) {
+	scene := NewScene()
+	camera := scene.NewPerspectiveCamera(75, 1.5, 0.1, 1000)
+	controls, err := scene.AddFirstPersonControls(camera, FirstPersonOptions{PointerLock: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(controls.JS, controls.ID+".pointerLock = true;") {
+		t.Errorf("Expected pointer lock to be enabled: %s", controls.JS)
+	}
+
+	var steps []struct {
+		Name   string
+		Locked bool
+		X, Z   float64
+	}
+	out := runNode(t, pointerLockTestJS, controlsJS)
+	if err := json.Unmarshal(out, &steps); err != nil || len(steps) != 3 {
+		t.Fatalf("Unexpected output: %s", out)
+	}
+	unlocked, locked, released := steps[0], steps[1], steps[2]
+	if unlocked.Locked || unlocked.X != 0 || unlocked.Z != -1 {
+		t.Errorf("Expected the camera to stay still before the pointer is locked: %+v", unlocked)
+	}
+	if !locked.Locked || locked.X == 0 {
+		t.Errorf("Expected the camera to turn when the mouse moves while locked: %+v", locked)
+	}
+	if released.Locked || released.X != locked.X || released.Z != locked.Z {
+		t.Errorf("Expected the camera to stay still after the lock is released: %+v", released)
+	}
+}
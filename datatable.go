@@ -0,0 +1,337 @@
+package onthefly
+
+import (
+	"errors"
+	"html"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// DataTable is a table of structs with server-side pagination, sorting and filtering.
+// The current page, sort column, sort order and filter are read from the query string,
+// using parameter names that are prefixed with the table ID, so that several tables
+// can be placed on the same page.
+type DataTable struct {
+	id       string
+	rows     any
+	pageSize int
+	columns  []string
+	filter   bool
+	jquery   bool
+	caption  string
+}
+
+// NewDataTable creates a new data table with the given ID, for the given slice of structs.
+// pageSize is the maximum number of rows that are shown at a time.
+func NewDataTable(id string, rows any, pageSize int) *DataTable {
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	return &DataTable{id: id, rows: rows, pageSize: pageSize, filter: true}
+}
+
+// SetColumns selects which struct fields (by Go field name) to show, and in which order
+func (dt *DataTable) SetColumns(columns ...string) {
+	dt.columns = columns
+}
+
+// SetCaption sets the table caption
+func (dt *DataTable) SetCaption(caption string) {
+	dt.caption = caption
+}
+
+// SetFilter enables or disables the filter box. It is enabled by default.
+func (dt *DataTable) SetFilter(enabled bool) {
+	dt.filter = enabled
+}
+
+// UseJQuery makes the sort links, pagination links and filter box refresh only the
+// table, by using the jQuery "load" function, instead of reloading the whole page.
+// The page must include jQuery for this to work, see Page.UseJQuery.
+func (dt *DataTable) UseJQuery() {
+	dt.jquery = true
+}
+
+// param returns the query string parameter name for the given setting
+func (dt *DataTable) param(name string) string {
+	return dt.id + "_" + name
+}
+
+// dataTableState is the state of a data table, as read from the query string
+type dataTableState struct {
+	page       int
+	sortColumn string
+	descending bool
+	query      string
+}
+
+// state reads the pagination, sorting and filter state from the given request
+func (dt *DataTable) state(req *http.Request) dataTableState {
+	var st dataTableState
+	values := req.URL.Query()
+	st.page, _ = strconv.Atoi(values.Get(dt.param("page")))
+	if st.page < 1 {
+		st.page = 1
+	}
+	st.sortColumn = values.Get(dt.param("sort"))
+	st.descending = values.Get(dt.param("order")) == "desc"
+	st.query = strings.TrimSpace(values.Get(dt.param("q")))
+	return st
+}
+
+// link returns a link to the current URL, with the given query string parameters changed
+func (dt *DataTable) link(req *http.Request, changes map[string]string) string {
+	values := req.URL.Query()
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := changes[key]; value == "" {
+			values.Del(dt.param(key))
+		} else {
+			values.Set(dt.param(key), value)
+		}
+	}
+	u := url.URL{Path: req.URL.Path, RawQuery: values.Encode()}
+	return html.EscapeString(u.String())
+}
+
+// lessValues compares two struct field values, for sorting
+func lessValues(a, b reflect.Value) bool {
+	if ta, ok := a.Interface().(time.Time); ok {
+		return ta.Before(b.Interface().(time.Time))
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	case reflect.String:
+		return strings.ToLower(a.String()) < strings.ToLower(b.String())
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() && !b.IsNil()
+		}
+		return lessValues(a.Elem(), b.Elem())
+	}
+	return formatValue(a, "") < formatValue(b, "")
+}
+
+// AddTo renders the filter box, the rows of the current page and the pagination links
+// as a child of the given tag, according to the state in the query string of the request.
+// Returns the <div> tag that contains the data table.
+func (dt *DataTable) AddTo(parent *Tag, req *http.Request) (*Tag, error) {
+	v := reflect.ValueOf(dt.rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.New("DataTable needs a slice of structs")
+	}
+	t := v.Type().Elem()
+	pointers := t.Kind() == reflect.Pointer
+	if pointers {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("DataTable needs a slice of structs")
+	}
+
+	// Find the columns
+	fields := structFields(t)
+	if len(dt.columns) > 0 {
+		var selected []structField
+		for _, name := range dt.columns {
+			for _, f := range fields {
+				if f.name == name {
+					selected = append(selected, f)
+				}
+			}
+		}
+		fields = selected
+	}
+	columns := make([]string, len(fields))
+	for i, f := range fields {
+		columns[i] = f.name
+	}
+
+	st := dt.state(req)
+
+	// Filter the rows
+	var indices []int
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		if pointers {
+			if row.IsNil() {
+				continue
+			}
+			row = row.Elem()
+		}
+		if st.query != "" {
+			found := false
+			for _, f := range fields {
+				if strings.Contains(strings.ToLower(formatValue(row.Field(f.index), f.format)), strings.ToLower(st.query)) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		indices = append(indices, i)
+	}
+
+	// Sort the rows
+	for _, f := range fields {
+		if f.name != st.sortColumn {
+			continue
+		}
+		fieldValue := func(i int) reflect.Value {
+			row := v.Index(i)
+			if pointers {
+				row = row.Elem()
+			}
+			return row.Field(f.index)
+		}
+		sort.SliceStable(indices, func(i, j int) bool {
+			if st.descending {
+				return lessValues(fieldValue(indices[j]), fieldValue(indices[i]))
+			}
+			return lessValues(fieldValue(indices[i]), fieldValue(indices[j]))
+		})
+	}
+
+	// Paginate the rows
+	pageCount := (len(indices) + dt.pageSize - 1) / dt.pageSize
+	if pageCount < 1 {
+		pageCount = 1
+	}
+	if st.page > pageCount {
+		st.page = pageCount
+	}
+	start := (st.page - 1) * dt.pageSize
+	end := min(start+dt.pageSize, len(indices))
+	pageRows := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, end-start)
+	for _, i := range indices[start:end] {
+		pageRows = reflect.Append(pageRows, v.Index(i))
+	}
+
+	div := parent.AddNewTag("div")
+	div.AddAttrib("id", dt.id)
+	div.AddAttrib("class", "datatable")
+
+	if dt.filter {
+		form := div.AddNewTag("form")
+		form.AddAttrib("method", "get")
+		form.AddAttrib("class", "datatable-filter")
+		if st.sortColumn != "" {
+			for _, key := range []string{"sort", "order"} {
+				value := req.URL.Query().Get(dt.param(key))
+				if value == "" {
+					continue
+				}
+				hidden := form.AddNewTag("input")
+				hidden.AddAttrib("type", "hidden")
+				hidden.AddAttrib("name", html.EscapeString(dt.param(key)))
+				hidden.AddAttrib("value", html.EscapeString(value))
+			}
+		}
+		search := form.AddNewTag("input")
+		search.AddAttrib("type", "search")
+		search.AddAttrib("name", html.EscapeString(dt.param("q")))
+		search.AddAttrib("placeholder", "Filter")
+		if st.query != "" {
+			search.AddAttrib("value", html.EscapeString(st.query))
+		}
+		form.AddNewTag("button").AddContent("Filter")
+	}
+
+	table, err := TableFromSlice(div, pageRows.Interface(), &TableOptions{Caption: dt.caption, Columns: columns})
+	if err != nil {
+		return nil, err
+	}
+
+	// Turn the column headers into sort links
+	th := table.FindChildByName("th")
+	for _, f := range fields {
+		if th == nil {
+			break
+		}
+		order, arrow := "asc", ""
+		if f.name == st.sortColumn {
+			if st.descending {
+				arrow = " &#9660;"
+				th.AddAttrib("aria-sort", "descending")
+			} else {
+				order, arrow = "desc", " &#9650;"
+				th.AddAttrib("aria-sort", "ascending")
+			}
+		}
+		href := dt.link(req, map[string]string{"sort": f.name, "order": order, "page": ""})
+		th.SetContent("<a href=\"" + href + "\">" + html.EscapeString(f.label) + "</a>" + arrow)
+		th = th.GetNextSibling()
+	}
+
+	// Add pagination links
+	if pageCount > 1 {
+		nav := div.AddNewTag("nav")
+		nav.AddAttrib("class", "pagination")
+		addLink := func(page int, text string) {
+			if page < 1 || page > pageCount || page == st.page {
+				span := nav.AddNewTag("span")
+				if page == st.page {
+					span.AddAttrib("class", "current")
+				}
+				span.AddContent(text)
+				return
+			}
+			a := nav.AddNewTag("a")
+			a.AddAttrib("href", dt.link(req, map[string]string{"page": strconv.Itoa(page)}))
+			a.AddContent(text)
+		}
+		addLink(st.page-1, "&laquo; Previous")
+		for page := 1; page <= pageCount; page++ {
+			addLink(page, strconv.Itoa(page))
+		}
+		addLink(st.page+1, "Next &raquo;")
+	}
+
+	if dt.jquery {
+		div.AddContent(Script(dt.jqueryStmts()...))
+	}
+
+	return div, nil
+}
+
+// jqueryStmts returns JavaScript that makes the links and the filter box of
+// the data table load the new contents into the table, instead of the whole page
+func (dt *DataTable) jqueryStmts() []js.Stmt {
+	sel := "#" + dt.id
+	links := sel + " thead a, " + sel + " .pagination a"
+	fragment := js.String(" " + sel + " > *")
+	document := js.Call(js.Ident("$"), js.Ident("document"))
+	handler := func(event, selector string, url js.Expr) js.Stmt {
+		load := Select(sel).Call("load", js.Concat(url, fragment))
+		callback := js.Func{Params: []string{"e"}, Body: []js.Stmt{js.ExprStmt(js.Method(js.Ident("e"), "preventDefault")), load}}
+		// Remove any previous handler, in case the table is rendered more than once
+		off := js.Method(document, "off", js.String(event), js.String(selector))
+		return js.ExprStmt(js.Method(off, "on", js.String(event), js.String(selector), callback))
+	}
+	pathname := js.Member(js.Member(js.Ident("window"), "location"), "pathname")
+	query := js.Concat(pathname, js.String("?"), js.Method(js.Call(js.Ident("$"), js.This), "serialize"))
+	return []js.Stmt{
+		handler("click", links, js.Member(js.This, "href")),
+		handler("submit", sel+" form", query),
+	}
+}
@@ -0,0 +1,83 @@
+package onthefly
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type testPerson struct {
+	Name string
+	Age  int
+}
+
+func testPeople() []testPerson {
+	var people []testPerson
+	for i := 1; i <= 25; i++ {
+		people = append(people, testPerson{"Person " + strconv.Itoa(i), 100 - i})
+	}
+	return people
+}
+
+func TestDataTablePagination(t *testing.T) {
+	dt := NewDataTable("people", testPeople(), 10)
+	req := httptest.NewRequest("GET", "/list?people_page=3", nil)
+	div, err := dt.AddTo(NewTag("body"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := div.FindChildByName("tbody").CountChildren(); n != 5 {
+		t.Errorf("Expected 5 rows on the last page, got %d", n)
+	}
+	s := div.String()
+	if !strings.Contains(s, "<span class=\"current\">3</span>") {
+		t.Errorf("Expected page 3 to be the current page:\n%s", s)
+	}
+	if !strings.Contains(s, "href=\"/list?people_page=2\"") {
+		t.Errorf("Expected a link to page 2:\n%s", s)
+	}
+}
+
+func TestDataTableSortAndFilter(t *testing.T) {
+	dt := NewDataTable("people", testPeople(), 10)
+	req := httptest.NewRequest("GET", "/?people_sort=Age&people_order=asc&people_q=person+1", nil)
+	div, err := dt.AddTo(NewTag("body"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "Person 1" and "Person 10" to "Person 19" match the filter
+	tbody := div.FindChildByName("tbody")
+	if n := tbody.CountChildren(); n != 10 {
+		t.Errorf("Expected 10 rows on the first page, got %d", n)
+	}
+	// Sorted by age, ascending, so "Person 19" comes first
+	if first := tbody.FindChildByName("td"); first.GetContent() != "Person 19" {
+		t.Errorf("Expected the first row to be \"Person 19\", got %q", first.GetContent())
+	}
+	s := div.String()
+	if !strings.Contains(s, "aria-sort=\"ascending\"") {
+		t.Errorf("Expected the Age column to be marked as sorted:\n%s", s)
+	}
+	if !strings.Contains(s, "people_order=desc") {
+		t.Errorf("Expected a link for sorting in descending order:\n%s", s)
+	}
+	if !strings.Contains(s, "value=\"person 1\"") {
+		t.Errorf("Expected the filter box to keep the query:\n%s", s)
+	}
+	if strings.Contains(s, "<script") {
+		t.Errorf("Did not expect any JavaScript without UseJQuery:\n%s", s)
+	}
+
+	dt.UseJQuery()
+	div, _ = dt.AddTo(NewTag("body"), req)
+	s = div.String()
+	for _, expected := range []string{
+		`$("#people").load(this.href + " #people \u003e *");`,
+		`$("#people").load(window.location.pathname + "?" + $(this).serialize() + " #people \u003e *");`,
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %s in:\n%s", expected, s)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package onthefly
+
+import (
+	"strconv"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// animationDuration is the duration of animated hiding and showing, in milliseconds.
+// This is the same as "normal" in jQuery.
+const animationDuration = 400
+
+// DOMBackend generates JavaScript for manipulating the DOM.
+// Selectors are CSS selectors, and apply to all matching elements, like in jQuery.
+type DOMBackend interface {
+	// OnDocumentReady runs the given statements when the HTML document is ready
+	OnDocumentReady(body ...js.Stmt) js.Stmt
+	// On runs the given statements when the given event, like "click", happens
+	On(selector, event string, body ...js.Stmt) js.Stmt
+	// Hide hides the matching elements
+	Hide(selector string) js.Stmt
+	// HideAnimated hides the matching elements in an animated way
+	HideAnimated(selector string) js.Stmt
+	// Show shows the matching elements
+	Show(selector string) js.Stmt
+	// ShowAnimated shows the matching elements in an animated way
+	ShowAnimated(selector string) js.Stmt
+	// ShowInline styles the matching elements with "display:inline"
+	ShowInline(selector string) js.Stmt
+	// Focus sets focus on the first matching element
+	Focus(selector string) js.Stmt
+	// SetText sets the text of the matching elements
+	SetText(selector, text string) js.Stmt
+	// SetHTML sets the HTML of the matching elements to the given expression
+	SetHTML(selector string, html js.Expr) js.Stmt
+	// SetValue sets the value of the matching elements to the given expression
+	SetValue(selector string, val js.Expr) js.Stmt
+	// Load loads the contents of the given URL into the matching elements
+	Load(selector, url string) js.Stmt
+	// Get fetches the given URL and runs the given statements, with the response text available as "data"
+	Get(url string, body ...js.Stmt) js.Stmt
+	// IfURLIsTrue runs the given statements if booleanURL returns "1"
+	IfURLIsTrue(booleanURL string, body ...js.Stmt) js.Stmt
+	// IfURLIsNotTrue runs the given statements if booleanURL does not return "1"
+	IfURLIsNotTrue(booleanURL string, body ...js.Stmt) js.Stmt
+	// ScrollDownAnimated slowly scrolls the page down
+	ScrollDownAnimated() js.Stmt
+}
+
+type (
+	jqueryDOM  struct{}
+	vanillaDOM struct{}
+)
+
+var (
+	// JQueryDOM is a DOM backend that uses jQuery. Scripts that are added with
+	// AddScriptOnReady include the embedded jQuery automatically, see Page.UseJQuery.
+	JQueryDOM DOMBackend = jqueryDOM{}
+	// VanillaDOM is a DOM backend that only uses the plain DOM APIs that
+	// are built into browsers, like document.querySelectorAll and fetch.
+	VanillaDOM DOMBackend = vanillaDOM{}
+)
+
+// SetDOM selects which DOM backend the page uses, either JQueryDOM or VanillaDOM
+func (page *Page) SetDOM(dom DOMBackend) {
+	page.dom = dom
+}
+
+// DOM returns the DOM backend of the page. JQueryDOM is the default.
+func (page *Page) DOM() DOMBackend {
+	if page.dom == nil {
+		return JQueryDOM
+	}
+	return page.dom
+}
+
+// AddScriptOnReady adds a script tag at the end of the body, that runs the
+// given statements when the document is ready, using the DOM backend of the page.
+// The embedded jQuery is included first when the backend is JQueryDOM.
+func (page *Page) AddScriptOnReady(stmts ...js.Stmt) (*Tag, error) {
+	if page.DOM() == JQueryDOM {
+		if err := page.UseJQuery(); err != nil {
+			return nil, err
+		}
+	}
+	return page.AddScriptToBody(page.DOM().OnDocumentReady(stmts...).String())
+}
+
+func (jqueryDOM) OnDocumentReady(body ...js.Stmt) js.Stmt {
+	return OnDocumentReadyStmt(body...)
+}
+
+func (jqueryDOM) On(selector, event string, body ...js.Stmt) js.Stmt {
+	return Select(selector).On(event, body...)
+}
+
+func (jqueryDOM) Hide(selector string) js.Stmt {
+	return Select(selector).Hide()
+}
+
+func (jqueryDOM) HideAnimated(selector string) js.Stmt {
+	return Select(selector).HideAnimated()
+}
+
+func (jqueryDOM) Show(selector string) js.Stmt {
+	return Select(selector).Show()
+}
+
+func (jqueryDOM) ShowAnimated(selector string) js.Stmt {
+	return Select(selector).ShowAnimated()
+}
+
+func (jqueryDOM) ShowInline(selector string) js.Stmt {
+	return Select(selector).ShowInline()
+}
+
+func (jqueryDOM) Focus(selector string) js.Stmt {
+	return Select(selector).Focus()
+}
+
+func (jqueryDOM) SetText(selector, text string) js.Stmt {
+	return Select(selector).SetText(text)
+}
+
+func (jqueryDOM) SetHTML(selector string, html js.Expr) js.Stmt {
+	return Select(selector).SetHTML(html)
+}
+
+func (jqueryDOM) SetValue(selector string, val js.Expr) js.Stmt {
+	return Select(selector).SetValue(val)
+}
+
+func (jqueryDOM) Load(selector, url string) js.Stmt {
+	return Select(selector).Load(url)
+}
+
+func (jqueryDOM) Get(url string, body ...js.Stmt) js.Stmt {
+	return js.ExprStmt(js.Call(js.Ident("$.get"), js.String(url), js.Func{Params: []string{"data"}, Body: body}))
+}
+
+func (jqueryDOM) IfURLIsTrue(booleanURL string, body ...js.Stmt) js.Stmt {
+	return IfURLIsTrue(booleanURL, body...)
+}
+
+func (jqueryDOM) IfURLIsNotTrue(booleanURL string, body ...js.Stmt) js.Stmt {
+	return IfURLIsNotTrue(booleanURL, body...)
+}
+
+func (jqueryDOM) ScrollDownAnimated() js.Stmt {
+	return ScrollDownAnimatedStmt()
+}
+
+// el is the current element, in the statements that are given to each
+var el = js.Ident("el")
+
+// style returns a reference to the given style property of the current element
+func style(property string) js.Expr {
+	return js.Member(js.Member(el, "style"), property)
+}
+
+// each returns a statement that runs the given statements for each element that
+// matches the selector. The current element is available as "el".
+func each(selector string, body ...js.Stmt) js.Stmt {
+	all := js.Method(js.Ident("document"), "querySelectorAll", js.String(selector))
+	return js.ExprStmt(js.Method(all, "forEach", js.Func{Params: []string{"el"}, Body: body}))
+}
+
+// after returns a statement that runs the given statements after the given number of milliseconds
+func after(ms int, body ...js.Stmt) js.Stmt {
+	return js.ExprStmt(js.Call(js.Ident("setTimeout"), js.Fn(body...), js.Value(ms)))
+}
+
+// fetchText returns an expression that fetches the given URL and then
+// runs the given statements, with the response text available as "data"
+func fetchText(url string, body ...js.Stmt) js.Expr {
+	response := js.Method(js.Call(js.Ident("fetch"), js.String(url)), "then", js.Func{
+		Params: []string{"r"},
+		Body:   []js.Stmt{js.Return(js.Method(js.Ident("r"), "text"))},
+	})
+	return js.Method(response, "then", js.Func{Params: []string{"data"}, Body: body})
+}
+
+// transition returns a statement that makes changes to the opacity of the current element animated
+func transition() js.Stmt {
+	return js.Assign(style("transition"), js.String("opacity "+strconv.Itoa(animationDuration)+"ms"))
+}
+
+// showElement returns a statement that shows the current element, also when it is hidden by a stylesheet
+func showElement() js.Stmt {
+	hidden := js.Raw(js.Call(js.Ident("getComputedStyle"), el).String() + ".display == \"none\"")
+	return js.Stmt(js.Assign(style("display"), js.String("")).String() +
+		js.If(hidden, js.Assign(style("display"), js.String("block"))).String())
+}
+
+func (vanillaDOM) OnDocumentReady(body ...js.Stmt) js.Stmt {
+	// Also run the statements if the document is already ready
+	ready := js.Func{Params: []string{"f"}, Body: []js.Stmt{
+		js.Stmt("if (document.readyState != \"loading\") { f(); } else { document.addEventListener(\"DOMContentLoaded\", f); }"),
+	}}
+	return js.ExprStmt(js.Call(js.Raw("("+ready.String()+")"), js.Fn(body...)))
+}
+
+func (vanillaDOM) On(selector, event string, body ...js.Stmt) js.Stmt {
+	return each(selector, js.ExprStmt(js.Method(el, "addEventListener", js.String(event), js.Fn(body...))))
+}
+
+func (vanillaDOM) Hide(selector string) js.Stmt {
+	return each(selector, js.Assign(style("display"), js.String("none")))
+}
+
+func (vanillaDOM) HideAnimated(selector string) js.Stmt {
+	return each(selector,
+		transition(),
+		js.Assign(style("opacity"), js.String("0")),
+		after(animationDuration, js.Assign(style("display"), js.String("none")), js.Assign(style("opacity"), js.String(""))),
+	)
+}
+
+func (vanillaDOM) Show(selector string) js.Stmt {
+	return each(selector, showElement())
+}
+
+func (vanillaDOM) ShowAnimated(selector string) js.Stmt {
+	return each(selector,
+		js.Assign(style("opacity"), js.String("0")),
+		showElement(),
+		transition(),
+		// Read the width, to make the browser apply the styles before changing the opacity
+		js.ExprStmt(js.Member(el, "offsetWidth")),
+		js.Assign(style("opacity"), js.String("1")),
+	)
+}
+
+func (vanillaDOM) ShowInline(selector string) js.Stmt {
+	return each(selector, js.Assign(style("display"), js.String("inline")))
+}
+
+func (vanillaDOM) Focus(selector string) js.Stmt {
+	first := js.Method(js.Ident("document"), "querySelector", js.String(selector))
+	focus := js.Func{Params: []string{"el"}, Body: []js.Stmt{js.If(el, js.ExprStmt(js.Method(el, "focus")))}}
+	return js.ExprStmt(js.Call(js.Raw("("+focus.String()+")"), first))
+}
+
+func (vanillaDOM) SetText(selector, text string) js.Stmt {
+	return each(selector, js.Assign(js.Member(el, "textContent"), js.String(text)))
+}
+
+func (vanillaDOM) SetHTML(selector string, html js.Expr) js.Stmt {
+	return each(selector, js.Assign(js.Member(el, "innerHTML"), html))
+}
+
+func (vanillaDOM) SetValue(selector string, val js.Expr) js.Stmt {
+	return each(selector, js.Assign(js.Member(el, "value"), val))
+}
+
+func (vanillaDOM) Load(selector, url string) js.Stmt {
+	return js.ExprStmt(fetchText(url, each(selector, js.Assign(js.Member(el, "innerHTML"), js.Ident("data")))))
+}
+
+func (vanillaDOM) Get(url string, body ...js.Stmt) js.Stmt {
+	return js.ExprStmt(fetchText(url, body...))
+}
+
+func (vanillaDOM) IfURLIsTrue(booleanURL string, body ...js.Stmt) js.Stmt {
+	return js.ExprStmt(fetchText(booleanURL, js.If(js.Raw("data == \"1\""), body...)))
+}
+
+func (vanillaDOM) IfURLIsNotTrue(booleanURL string, body ...js.Stmt) js.Stmt {
+	return js.ExprStmt(fetchText(booleanURL, js.If(js.Raw("data != \"1\""), body...)))
+}
+
+func (vanillaDOM) ScrollDownAnimated() js.Stmt {
+	height := js.Ident("document.body.scrollHeight")
+	return js.ExprStmt(js.Call(js.Ident("window.scrollTo"), js.Object{"top": height, "behavior": js.String("smooth")}))
+}
@@ -0,0 +1,77 @@
+package onthefly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+func TestPageDOM(t *testing.T) {
+	page := NewHTML5Page("DOM")
+	if page.DOM() != JQueryDOM {
+		t.Error("Expected jQuery to be the default DOM backend")
+	}
+	if _, err := page.AddScriptOnReady(page.DOM().Hide("#a")); err != nil {
+		t.Fatal(err)
+	}
+	if !page.hasJQuery() {
+		t.Error("Expected jQuery to be included for the jQuery backend")
+	}
+	page = NewHTML5Page("DOM")
+	page.SetDOM(VanillaDOM)
+	if page.DOM() != VanillaDOM {
+		t.Error("Expected the DOM backend to be changed")
+	}
+	if _, err := page.AddScriptOnReady(page.DOM().Hide("#a")); err != nil {
+		t.Fatal(err)
+	}
+	s := page.String()
+	if !strings.Contains(s, "DOMContentLoaded") || !strings.Contains(s, "querySelectorAll(\"#a\")") {
+		t.Errorf("Expected plain DOM code in the page:\n%s", s)
+	}
+	if page.hasJQuery() {
+		t.Error("Did not expect jQuery to be included for the plain DOM backend")
+	}
+}
+
+func TestVanillaDOM(t *testing.T) {
+	dom := VanillaDOM
+	stmts := []js.Stmt{
+		dom.OnDocumentReady(dom.Focus("#name")),
+		dom.On("#button", "click", dom.ShowAnimated(".hidden"), dom.HideAnimated("#button")),
+		dom.Show("p"),
+		dom.ShowInline("span"),
+		dom.SetText("#out", "</script>"),
+		dom.SetHTML("#out", js.String("<b>hi</b>")),
+		dom.SetValue("input", js.String("x")),
+		dom.Load("#content", "/content"),
+		dom.IfURLIsTrue("/visible", dom.Show("#a")),
+		dom.IfURLIsNotTrue("/visible", dom.Hide("#a")),
+		dom.ScrollDownAnimated(),
+	}
+	for _, stmt := range stmts {
+		s := stmt.String()
+		if strings.Contains(s, "$(") || strings.Contains(s, "$.") {
+			t.Errorf("Expected no jQuery in %s", s)
+		}
+		if strings.Contains(s, "</script") {
+			t.Errorf("Expected strings to be escaped in %s", s)
+		}
+	}
+	if s := dom.Load("#content", "/content").String(); !strings.HasPrefix(s, "fetch(\"/content\")") {
+		t.Errorf("Expected Load to use fetch, got %s", s)
+	}
+	if s := dom.HideAnimated("#a").String(); !strings.Contains(s, "transition") {
+		t.Errorf("Expected a CSS transition, got %s", s)
+	}
+}
+
+func TestJQueryDOM(t *testing.T) {
+	if got, want := JQueryDOM.Hide("#a").String(), Hide("#a"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if got, want := JQueryDOM.On("#a", "click", AlertStmt("hi")).String(), OnClick("#a", Alert("hi")); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
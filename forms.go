@@ -0,0 +1,365 @@
+package onthefly
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ErrInvalidForm is returned by Form.Bind when one or more fields did not validate
+var ErrInvalidForm = errors.New("the submitted form has invalid fields")
+
+type (
+	// Option is a value and label pair for a <select> field
+	Option struct {
+		Value string
+		Label string
+	}
+	// Field represents a single form field.
+	// For numeric fields ("number" and "range"), Min and Max are the allowed range.
+	// For other fields, Min and Max are the allowed length of the value.
+	Field struct {
+		Name        string
+		Label       string
+		Type        string // "text", "email", "number", "password", "textarea", "select", "checkbox" and so on
+		Value       string
+		Placeholder string
+		Options     []Option
+		Required    bool
+		Pattern     string
+		Min, Max    string
+		Step        string
+		Checked     bool
+		Error       string
+	}
+	// Form represents an HTML form that can be rendered, submitted, validated
+	// and then rendered again with the submitted values and error messages
+	Form struct {
+		action    string
+		method    string
+		submit    string
+		csrfName  string
+		csrfToken string
+		formError string
+		fields    []*Field
+	}
+)
+
+// NewForm creates a new form that will be submitted to the given action URL,
+// using the given method, for instance "POST"
+func NewForm(action, method string) *Form {
+	return &Form{action: action, method: method, submit: "Submit"}
+}
+
+// NewCSRFToken returns a random token that can be used with Form.SetCSRF
+func NewCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AddField adds a field to the form and returns it
+func (form *Form) AddField(field *Field) *Field {
+	form.fields = append(form.fields, field)
+	return field
+}
+
+// AddInput adds an <input> field with the given name, label and input type, for instance "email"
+func (form *Form) AddInput(name, label, inputType string) *Field {
+	return form.AddField(&Field{Name: name, Label: label, Type: inputType})
+}
+
+// AddTextarea adds a <textarea> field
+func (form *Form) AddTextarea(name, label string) *Field {
+	return form.AddField(&Field{Name: name, Label: label, Type: "textarea"})
+}
+
+// AddSelect adds a <select> field with the given options
+func (form *Form) AddSelect(name, label string, options ...Option) *Field {
+	return form.AddField(&Field{Name: name, Label: label, Type: "select", Options: options})
+}
+
+// AddCheckbox adds a checkbox field
+func (form *Form) AddCheckbox(name, label string) *Field {
+	return form.AddField(&Field{Name: name, Label: label, Type: "checkbox"})
+}
+
+// SetCSRF adds a hidden field with the given name and token.
+// Bind will reject submissions where the token does not match.
+func (form *Form) SetCSRF(name, token string) {
+	form.csrfName = name
+	form.csrfToken = token
+}
+
+// SetSubmitText sets the text on the submit button
+func (form *Form) SetSubmitText(text string) {
+	form.submit = text
+}
+
+// Field returns the field with the given name, or nil if not found
+func (form *Form) Field(name string) *Field {
+	for _, field := range form.fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// Fields returns all fields of the form
+func (form *Form) Fields() []*Field {
+	return form.fields
+}
+
+// Values returns the current value of every field, by name
+func (form *Form) Values() map[string]string {
+	values := make(map[string]string, len(form.fields))
+	for _, field := range form.fields {
+		values[field.Name] = field.Value
+	}
+	return values
+}
+
+// Errors returns the error message of every field that did not validate, by name
+func (form *Form) Errors() map[string]string {
+	errs := make(map[string]string)
+	for _, field := range form.fields {
+		if field.Error != "" {
+			errs[field.Name] = field.Error
+		}
+	}
+	return errs
+}
+
+// Bind parses the submitted values of the given request and validates them.
+// The values can be form-encoded or, if the content type is application/json, a JSON object.
+// The values are kept in the form, so that it can be rendered again with the
+// submitted values and per-field error messages filled in.
+// Returns ErrInvalidForm if one or more fields did not validate.
+func (form *Form) Bind(req *http.Request) error {
+	values, err := requestValues(req)
+	if err != nil {
+		return err
+	}
+	form.formError = ""
+	valid := true
+	if form.csrfName != "" {
+		submitted := values.Get(form.csrfName)
+		if subtle.ConstantTimeCompare([]byte(submitted), []byte(form.csrfToken)) != 1 {
+			form.formError = "The form has expired, please submit it again."
+			valid = false
+		}
+	}
+	for _, field := range form.fields {
+		_, submitted := values[field.Name]
+		value := values.Get(field.Name)
+		if field.Type == "checkbox" {
+			field.Checked = submitted
+		} else {
+			field.Value = value
+		}
+		field.Error = field.validate(value, submitted)
+		if field.Error != "" {
+			valid = false
+		}
+	}
+	if !valid {
+		return ErrInvalidForm
+	}
+	return nil
+}
+
+// isNumeric checks if the field holds a number
+func (field *Field) isNumeric() bool {
+	return field.Type == "number" || field.Type == "range"
+}
+
+// validate checks the given submitted value against the constraints of the field.
+// Returns an error message, or an empty string if the value is valid.
+func (field *Field) validate(value string, submitted bool) string {
+	if field.Type == "checkbox" {
+		if field.Required && !submitted {
+			return "This box must be checked."
+		}
+		return ""
+	}
+	if value == "" {
+		if field.Required {
+			return "This field is required."
+		}
+		return ""
+	}
+	if field.Type == "email" {
+		if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+			return "Please enter a valid e-mail address."
+		}
+	}
+	if field.Type == "select" {
+		found := false
+		for _, option := range field.Options {
+			if option.Value == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "Please select one of the options."
+		}
+	}
+	if field.isNumeric() {
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "Please enter a number."
+		}
+		if min, err := strconv.ParseFloat(field.Min, 64); err == nil && x < min {
+			return "The value must be at least " + field.Min + "."
+		}
+		if max, err := strconv.ParseFloat(field.Max, 64); err == nil && x > max {
+			return "The value must be at most " + field.Max + "."
+		}
+	} else {
+		length := utf8.RuneCountInString(value)
+		if min, err := strconv.Atoi(field.Min); err == nil && length < min {
+			return "The value must be at least " + field.Min + " characters long."
+		}
+		if max, err := strconv.Atoi(field.Max); err == nil && length > max {
+			return "The value must be at most " + field.Max + " characters long."
+		}
+	}
+	if field.Pattern != "" {
+		// Like in the browser, the pattern must match the entire value
+		re, err := regexp.Compile("^(?:" + field.Pattern + ")$")
+		if err != nil || !re.MatchString(value) {
+			return "The value is not in the expected format."
+		}
+	}
+	return ""
+}
+
+// addConstraints adds the HTML attributes that lets the browser validate the field as well
+func (field *Field) addConstraints(tag *Tag) {
+	if field.Required {
+		tag.AddSingularAttrib("required")
+	}
+	if field.Pattern != "" && field.Type != "textarea" {
+		tag.AddAttrib("pattern", html.EscapeString(field.Pattern))
+	}
+	if field.isNumeric() {
+		if field.Min != "" {
+			tag.AddAttrib("min", html.EscapeString(field.Min))
+		}
+		if field.Max != "" {
+			tag.AddAttrib("max", html.EscapeString(field.Max))
+		}
+		if field.Step != "" {
+			tag.AddAttrib("step", html.EscapeString(field.Step))
+		}
+	} else {
+		if field.Min != "" {
+			tag.AddAttrib("minlength", html.EscapeString(field.Min))
+		}
+		if field.Max != "" {
+			tag.AddAttrib("maxlength", html.EscapeString(field.Max))
+		}
+	}
+	if field.Placeholder != "" {
+		tag.AddAttrib("placeholder", html.EscapeString(field.Placeholder))
+	}
+}
+
+// addLabel adds a <label> for the field
+func (field *Field) addLabel(tag *Tag) {
+	if field.Label == "" {
+		return
+	}
+	label := tag.AddNewTag("label")
+	label.AddAttrib("for", html.EscapeString(field.Name))
+	label.AddContent(html.EscapeString(field.Label))
+}
+
+// AddTo renders the field as a <div> with a label, the input element and any error message
+func (field *Field) AddTo(parent *Tag) *Tag {
+	div := parent.AddNewTag("div")
+	div.AddAttrib("class", "field")
+	name := html.EscapeString(field.Name)
+	if field.Type != "checkbox" {
+		field.addLabel(div)
+	}
+	var input *Tag
+	switch field.Type {
+	case "textarea":
+		input = div.AddNewTag("textarea")
+		// A newline right after <textarea> is ignored by browsers,
+		// and makes sure the tag is never rendered as <textarea />
+		input.AddContent("\n" + html.EscapeString(field.Value))
+	case "select":
+		input = div.AddNewTag("select")
+		for _, option := range field.Options {
+			o := input.AddNewTag("option")
+			o.AddAttrib("value", html.EscapeString(option.Value))
+			if option.Value == field.Value {
+				o.AddSingularAttrib("selected")
+			}
+			o.AddContent(html.EscapeString(option.Label))
+		}
+	default:
+		input = div.AddNewTag("input")
+		input.AddAttrib("type", html.EscapeString(field.Type))
+		if field.Type == "checkbox" {
+			if field.Checked {
+				input.AddSingularAttrib("checked")
+			}
+		} else if field.Type != "password" && field.Value != "" {
+			input.AddAttrib("value", html.EscapeString(field.Value))
+		}
+	}
+	input.AddAttrib("id", name)
+	input.AddAttrib("name", name)
+	field.addConstraints(input)
+	if field.Type == "checkbox" {
+		field.addLabel(div)
+	}
+	if field.Error != "" {
+		div.AddAttrib("class", "field invalid")
+		input.AddAttrib("aria-invalid", "true")
+		span := div.AddNewTag("span")
+		span.AddAttrib("class", "error")
+		span.AddContent(html.EscapeString(field.Error))
+	}
+	return div
+}
+
+// AddForm renders the given form as a child of this tag.
+// Returns the <form> tag.
+func (tag *Tag) AddForm(form *Form) *Tag {
+	formTag := tag.AddNewTag("form")
+	formTag.AddAttrib("action", html.EscapeString(form.action))
+	formTag.AddAttrib("method", html.EscapeString(form.method))
+	if form.formError != "" {
+		p := formTag.AddNewTag("p")
+		p.AddAttrib("class", "form-error")
+		p.AddContent(html.EscapeString(form.formError))
+	}
+	if form.csrfName != "" {
+		hidden := formTag.AddNewTag("input")
+		hidden.AddAttrib("type", "hidden")
+		hidden.AddAttrib("name", html.EscapeString(form.csrfName))
+		hidden.AddAttrib("value", html.EscapeString(form.csrfToken))
+	}
+	for _, field := range form.fields {
+		field.AddTo(formTag)
+	}
+	button := formTag.AddNewTag("button")
+	button.AddAttrib("type", "submit")
+	button.AddContent(html.EscapeString(form.submit))
+	return formTag
+}
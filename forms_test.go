@@ -0,0 +1,127 @@
+package onthefly
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newTestForm() *Form {
+	form := NewForm("/signup", "POST")
+	form.SetCSRF("csrf", "secret")
+	name := form.AddInput("name", "Name", "text")
+	name.Required = true
+	name.Max = "10"
+	email := form.AddInput("email", "E-mail", "email")
+	email.Required = true
+	age := form.AddInput("age", "Age", "number")
+	age.Min = "18"
+	age.Max = "120"
+	code := form.AddInput("code", "Code", "text")
+	code.Pattern = "[A-Z]{3}"
+	form.AddSelect("color", "Color", Option{"r", "Red"}, Option{"g", "Green"})
+	form.AddCheckbox("terms", "I agree").Required = true
+	form.AddTextarea("bio", "About you")
+	return form
+}
+
+func TestFormRender(t *testing.T) {
+	form := newTestForm()
+	body := NewTag("body")
+	body.AddForm(form)
+	s := body.String()
+	for _, expected := range []string{
+		"<form",
+		"type=\"hidden\"",
+		"value=\"secret\"",
+		"<label for=\"name\">Name</label>",
+		"<option value=\"g\">Green</option>",
+		"type=\"checkbox\"",
+		"<textarea",
+		"</textarea>",
+		"maxlength=\"10\"",
+		"min=\"18\"",
+		"<button type=\"submit\">Submit</button>",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in the rendered form:\n%s", expected, s)
+		}
+	}
+}
+
+func TestFormBind(t *testing.T) {
+	form := newTestForm()
+	values := url.Values{
+		"csrf":  {"secret"},
+		"name":  {"<b>Bob</b> the builder"},
+		"email": {"not an email"},
+		"age":   {"12"},
+		"code":  {"ABCD"},
+		"color": {"g"},
+		"bio":   {"Hello\n</textarea>"},
+	}
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := form.Bind(req); err != ErrInvalidForm {
+		t.Fatalf("Expected ErrInvalidForm, got %v", err)
+	}
+	errs := form.Errors()
+	for _, name := range []string{"name", "email", "age", "code", "terms"} {
+		if errs[name] == "" {
+			t.Errorf("Expected an error for field %q", name)
+		}
+	}
+	if _, found := errs["color"]; found {
+		t.Errorf("Did not expect an error for the color field: %s", errs["color"])
+	}
+
+	// Render the form again, with sticky values and error messages
+	body := NewTag("body")
+	body.AddForm(form)
+	s := body.String()
+	if strings.Contains(s, "<b>Bob</b>") || strings.Contains(s, "Hello\n</textarea>") {
+		t.Errorf("Submitted values must be escaped:\n%s", s)
+	}
+	if !strings.Contains(s, "&lt;b&gt;Bob&lt;/b&gt; the builder") {
+		t.Errorf("Expected the submitted name to be kept:\n%s", s)
+	}
+	if option := body.FindChildByAttribute("value", "g"); option == nil || !option.HasAttribute("selected") {
+		t.Errorf("Expected the submitted option to be selected:\n%s", s)
+	}
+	if strings.Count(s, "class=\"error\"") != len(errs) {
+		t.Errorf("Expected %d error messages:\n%s", len(errs), s)
+	}
+}
+
+func TestFormBindValid(t *testing.T) {
+	form := newTestForm()
+	values := url.Values{
+		"csrf":  {"secret"},
+		"name":  {"Bob"},
+		"email": {"bob@example.com"},
+		"age":   {"42"},
+		"code":  {"ABC"},
+		"color": {"r"},
+		"terms": {"on"},
+	}
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := form.Bind(req); err != nil {
+		t.Fatalf("Expected the form to validate, got %v: %v", err, form.Errors())
+	}
+	if form.Values()["email"] != "bob@example.com" {
+		t.Errorf("Expected the email value to be bound, got %q", form.Values()["email"])
+	}
+	if !form.Field("terms").Checked {
+		t.Error("Expected the terms checkbox to be checked")
+	}
+
+	// A wrong CSRF token must be rejected
+	values.Set("csrf", "wrong")
+	req = httptest.NewRequest("POST", "/signup", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := form.Bind(req); err != ErrInvalidForm {
+		t.Errorf("Expected ErrInvalidForm for a wrong CSRF token, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package onthefly
+
+import (
+	"html"
+	"net/http"
+)
+
+// Swap strategies for partial page updates, for use with Tag.FragmentSwap
+const (
+	SwapInner       = "innerHTML"   // replace the contents of the target (the default)
+	SwapOuter       = "outerHTML"   // replace the target itself
+	SwapBeforeBegin = "beforebegin" // insert before the target
+	SwapAfterBegin  = "afterbegin"  // insert before the first child of the target
+	SwapBeforeEnd   = "beforeend"   // insert after the last child of the target
+	SwapAfterEnd    = "afterend"    // insert after the target
+	SwapDelete      = "delete"      // remove the target and ignore the response
+	SwapNone        = "none"        // only make the request
+)
+
+// fragmentsID is the ID of the script tag that includes the fragments client script
+const fragmentsID = "onthefly-fragments"
+
+// FragmentGet makes the tag fetch HTML from the given URL with a GET request when it is
+// triggered, and then swap the HTML into the target. If the tag is a form or is inside a form,
+// the form values are sent along. The page must include the client script, see Page.UseFragments.
+func (tag *Tag) FragmentGet(url string) {
+	tag.AddAttrib("data-fragment-url", html.EscapeString(url))
+	tag.AddAttrib("data-fragment-method", "get")
+}
+
+// FragmentPost is like FragmentGet, but uses a POST request
+func (tag *Tag) FragmentPost(url string) {
+	tag.AddAttrib("data-fragment-url", html.EscapeString(url))
+	tag.AddAttrib("data-fragment-method", "post")
+}
+
+// FragmentTrigger sets which event makes the tag fetch the fragment, for example "click",
+// "change", "keyup", "load" (when the page is loaded) or "every 2s" (polling, also "500ms").
+// The default is "submit" for forms, "change" for input fields and "click" for other tags.
+func (tag *Tag) FragmentTrigger(event string) {
+	tag.AddAttrib("data-fragment-trigger", html.EscapeString(event))
+}
+
+// FragmentTarget sets the CSS selector of the tag that the fragment is swapped into.
+// The default is the tag itself.
+func (tag *Tag) FragmentTarget(selector string) {
+	tag.AddAttrib("data-fragment-target", html.EscapeString(selector))
+}
+
+// FragmentSwap sets how the fragment is swapped into the target, for example SwapOuter.
+// The default is SwapInner.
+func (tag *Tag) FragmentSwap(strategy string) {
+	tag.AddAttrib("data-fragment-swap", html.EscapeString(strategy))
+}
+
+// UseFragments makes sure that the page includes the embedded client script for
+// partial page updates, in the head. It is only added once.
+func (page *Page) UseFragments() error {
+	if page.root.FindChildByAttribute("id", fragmentsID) != nil {
+		return nil
+	}
+	head, err := page.GetTag("head")
+	if err != nil {
+		return err
+	}
+	script := head.AddNewTag("script")
+	script.AddAttrib("id", fragmentsID)
+	script.AddAttrib("type", "text/javascript")
+	script.AddContent(fragmentsJS)
+	return nil
+}
+
+// IsFragmentRequest checks if the request was made by the fragments client script
+func IsFragmentRequest(req *http.Request) bool {
+	return req.Header.Get("X-Fragment") == "true"
+}
+
+// writeFragment writes the given tag as HTML, or responds with 404 if the tag is nil
+func writeFragment(w http.ResponseWriter, req *http.Request, tag *Tag) {
+	if tag == nil {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(tag.String()))
+}
+
+// FragmentHandler returns a handler that calls the given function for each request,
+// and responds with the HTML of the returned tag. If the tag is nil, the response is 404.
+func FragmentHandler(render func(req *http.Request) *Tag) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		writeFragment(w, req, render(req))
+	}
+}
+
+// PageFragmentHandler returns a handler that calls the given function for each request,
+// and responds with the HTML of the tag with the requested ID, so that regions of a page
+// can be refreshed by ID. The ID is taken from the "fragment" query parameter, or else from the
+// ID of the target that the client script sends. For other requests, the whole page is served.
+func PageFragmentHandler(render func(req *http.Request) *Page) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		page := render(req)
+		if page == nil {
+			http.NotFound(w, req)
+			return
+		}
+		id := req.URL.Query().Get("fragment")
+		if id == "" {
+			id = req.Header.Get("X-Fragment-Target")
+		}
+		if id == "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page.String()))
+			return
+		}
+		writeFragment(w, req, page.root.FindChildByAttribute("id", html.EscapeString(id)))
+	}
+}
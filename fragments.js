@@ -0,0 +1,134 @@
+// Partial page updates for onthefly, configured with data-fragment-* attributes
+(function() {
+	"use strict";
+
+	function attr(el, name) {
+		return el.getAttribute("data-fragment-" + name);
+	}
+
+	function defaultTrigger(el) {
+		if (el.tagName == "FORM") {
+			return "submit";
+		}
+		if (el.tagName == "INPUT" || el.tagName == "SELECT" || el.tagName == "TEXTAREA") {
+			return "change";
+		}
+		return "click";
+	}
+
+	function targetOf(el) {
+		var selector = attr(el, "target");
+		if (!selector || selector == "this") {
+			return el;
+		}
+		return document.querySelector(selector);
+	}
+
+	function swap(target, html, strategy) {
+		var parent = target.parentNode;
+		switch (strategy) {
+		case "outerHTML":
+			var template = document.createElement("template");
+			template.innerHTML = html;
+			var nodes = Array.prototype.slice.call(template.content.childNodes);
+			target.replaceWith.apply(target, nodes);
+			nodes.forEach(function(node) {
+				if (node.nodeType == 1) {
+					process(node);
+				}
+			});
+			break;
+		case "beforebegin":
+		case "afterbegin":
+		case "beforeend":
+		case "afterend":
+			target.insertAdjacentHTML(strategy, html);
+			process(parent || target);
+			break;
+		case "delete":
+			target.remove();
+			break;
+		case "none":
+			break;
+		default:
+			target.innerHTML = html;
+			process(target);
+		}
+	}
+
+	function request(el) {
+		var url = attr(el, "url");
+		var method = (attr(el, "method") || "get").toUpperCase();
+		var target = targetOf(el);
+		if (!url || !target) {
+			return;
+		}
+		var options = {method: method, headers: {"X-Fragment": "true"}};
+		if (target.id) {
+			options.headers["X-Fragment-Target"] = target.id;
+		}
+		var form = el.tagName == "FORM" ? el : el.closest("form");
+		var data = null;
+		if (form) {
+			data = new URLSearchParams(new FormData(form));
+		} else if (el.name) {
+			data = new URLSearchParams();
+			data.append(el.name, el.value);
+		}
+		if (data && method == "GET") {
+			url += (url.indexOf("?") < 0 ? "?" : "&") + data;
+		} else if (data) {
+			options.body = data;
+		}
+		fetch(url, options).then(function(r) {
+			if (!r.ok) {
+				throw new Error(r.status + " " + r.statusText);
+			}
+			return r.text();
+		}).then(function(html) {
+			swap(target, html, attr(el, "swap") || "innerHTML");
+		}).catch(function(err) {
+			el.dispatchEvent(new CustomEvent("fragment:error", {bubbles: true, detail: err}));
+		});
+	}
+
+	function setup(el) {
+		if (el.fragmentReady) {
+			return;
+		}
+		el.fragmentReady = true;
+		var trigger = attr(el, "trigger") || defaultTrigger(el);
+		var every = /^every\s+(\d+(?:\.\d+)?)(ms|s)$/.exec(trigger);
+		if (every) {
+			var timer = setInterval(function() {
+				if (!document.contains(el)) {
+					clearInterval(timer);
+					return;
+				}
+				request(el);
+			}, parseFloat(every[1]) * (every[2] == "s" ? 1000 : 1));
+		} else if (trigger == "load") {
+			request(el);
+		} else {
+			el.addEventListener(trigger, function(e) {
+				e.preventDefault();
+				request(el);
+			});
+		}
+	}
+
+	function process(root) {
+		if (root.matches && root.matches("[data-fragment-url]")) {
+			setup(root);
+		}
+		root.querySelectorAll("[data-fragment-url]").forEach(setup);
+	}
+
+	if (document.readyState != "loading") {
+		process(document);
+	} else {
+		document.addEventListener("DOMContentLoaded", function() {
+			process(document);
+		});
+	}
+})();
@@ -0,0 +1,102 @@
+package onthefly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFragmentAttributes(t *testing.T) {
+	button := NewTag("button")
+	button.FragmentPost("/items?sort=name&x=\"")
+	button.FragmentTrigger("every 2s")
+	button.FragmentTarget("#items")
+	button.FragmentSwap(SwapBeforeEnd)
+	for name, expected := range map[string]string{
+		"data-fragment-url":     "/items?sort=name&amp;x=&#34;",
+		"data-fragment-method":  "post",
+		"data-fragment-trigger": "every 2s",
+		"data-fragment-target":  "#items",
+		"data-fragment-swap":    "beforeend",
+	} {
+		if value, _ := button.GetAttribute(name); value != expected {
+			t.Errorf("Expected %s to be %q, got %q", name, expected, value)
+		}
+	}
+}
+
+func TestUseFragments(t *testing.T) {
+	page := NewHTML5Page("Fragments")
+	for i := 0; i < 2; i++ {
+		if err := page.UseFragments(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(page.String(), "id=\""+fragmentsID+"\""); n != 1 {
+		t.Errorf("Expected the client script to be included once, got %d", n)
+	}
+}
+
+func testFragmentPage(*http.Request) *Page {
+	page := NewHTML5Page("Regions")
+	body, _ := page.GetTag("body")
+	clock := body.AddNewTag("div")
+	clock.AddAttrib("id", "clock")
+	clock.AddContent("12:00")
+	clock.FragmentGet("/")
+	clock.FragmentTrigger("every 1s")
+	clock.FragmentSwap(SwapOuter)
+	body.AddNewTag("p").AddContent("Other content")
+	return page
+}
+
+func TestPageFragmentHandler(t *testing.T) {
+	handler := PageFragmentHandler(testFragmentPage)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Fragment", "true")
+	req.Header.Set("X-Fragment-Target", "clock")
+	if !IsFragmentRequest(req) {
+		t.Error("Expected a fragment request")
+	}
+	handler(rec, req)
+	s := rec.Body.String()
+	if !strings.HasPrefix(s, "<div") || !strings.Contains(s, "12:00") || strings.Contains(s, "Other content") {
+		t.Errorf("Expected only the clock region, got:\n%s", s)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/?fragment=missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing region, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/", nil))
+	if s := rec.Body.String(); !strings.Contains(s, "Other content") {
+		t.Errorf("Expected the whole page, got:\n%s", s)
+	}
+}
+
+func TestFragmentHandler(t *testing.T) {
+	handler := FragmentHandler(func(req *http.Request) *Tag {
+		if req.URL.Query().Get("name") == "" {
+			return nil
+		}
+		p := NewTag("p")
+		p.AddContent("Hello, " + req.URL.Query().Get("name"))
+		return p
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/hello?name=Bob", nil))
+	if s := strings.TrimSpace(rec.Body.String()); s != "<p>Hello, Bob</p>" {
+		t.Errorf("Expected a single paragraph, got %q", s)
+	}
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/hello", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
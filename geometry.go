@@ -0,0 +1,279 @@
+package onthefly
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/xyproto/onthefly/js"
+	"github.com/xyproto/tinysvg"
+)
+
+// Vector3 is a point or a direction in 3D
+type Vector3 struct {
+	X, Y, Z float64
+}
+
+// vector2s returns the given 2D points as an array of THREE.Vector2
+func vector2s(points []*tinysvg.Pos) js.Array {
+	a := make(js.Array, len(points))
+	for i, p := range points {
+		a[i] = js.New(threeClass("Vector2"), nums(p.X, p.Y)...)
+	}
+	return a
+}
+
+// vector3s returns the given 3D points as an array of THREE.Vector3
+func vector3s(points []Vector3) js.Array {
+	a := make(js.Array, len(points))
+	for i, p := range points {
+		a[i] = js.New(threeClass("Vector3"), nums(p.X, p.Y, p.Z)...)
+	}
+	return a
+}
+
+// newGeometry declares a geometry of the given Three.JS class
+func (s *Scene) newGeometry(class string, args ...js.Expr) *Geometry {
+	id := s.nextID(geometryPrefix)
+	return s.addGeometry(&Geometry{ID: id, JS: newThree(id, class, args...)})
+}
+
+// NewTorusGeometry creates geometry for a torus (a donut)
+func (s *Scene) NewTorusGeometry(radius, tube float64, radialSegments, tubularSegments int) (*Geometry, error) {
+	if err := checkSegments(radialSegments, tubularSegments); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("TorusGeometry", js.Value(radius), js.Value(tube), js.Value(radialSegments), js.Value(tubularSegments)), nil
+}
+
+// NewTorusKnotGeometry creates geometry for a torus knot. p and q are how many times
+// the knot winds around its axis of rotational symmetry and around the interior of the torus.
+func (s *Scene) NewTorusKnotGeometry(radius, tube float64, tubularSegments, radialSegments, p, q int) (*Geometry, error) {
+	if err := checkSegments(tubularSegments, radialSegments); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("TorusKnotGeometry", js.Value(radius), js.Value(tube), js.Value(tubularSegments), js.Value(radialSegments), js.Value(p), js.Value(q)), nil
+}
+
+// NewConeGeometry creates geometry for a cone
+func (s *Scene) NewConeGeometry(radius, height float64, radialSegments int) (*Geometry, error) {
+	if err := checkSegments(radialSegments); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("ConeGeometry", js.Value(radius), js.Value(height), js.Value(radialSegments)), nil
+}
+
+// NewRingGeometry creates geometry for a flat ring
+func (s *Scene) NewRingGeometry(innerRadius, outerRadius float64, thetaSegments int) (*Geometry, error) {
+	if err := checkSegments(thetaSegments); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("RingGeometry", js.Value(innerRadius), js.Value(outerRadius), js.Value(thetaSegments)), nil
+}
+
+// NewCircleGeometry creates geometry for a flat circle
+func (s *Scene) NewCircleGeometry(radius float64, segments int) (*Geometry, error) {
+	if err := checkSegments(segments); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("CircleGeometry", js.Value(radius), js.Value(segments)), nil
+}
+
+// NewCapsuleGeometry creates geometry for a capsule, a cylinder with half spheres at the ends
+func (s *Scene) NewCapsuleGeometry(radius, length float64, capSegments, radialSegments int) (*Geometry, error) {
+	if err := checkSegments(capSegments, radialSegments); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("CapsuleGeometry", js.Value(radius), js.Value(length), js.Value(capSegments), js.Value(radialSegments)), nil
+}
+
+// NewIcosahedronGeometry creates geometry for an icosahedron (20 sides).
+// A detail above 0 makes it more like a sphere.
+func (s *Scene) NewIcosahedronGeometry(radius float64, detail int) (*Geometry, error) {
+	if err := checkSegments(detail); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("IcosahedronGeometry", js.Value(radius), js.Value(detail)), nil
+}
+
+// NewDodecahedronGeometry creates geometry for a dodecahedron (12 sides).
+// A detail above 0 makes it more like a sphere.
+func (s *Scene) NewDodecahedronGeometry(radius float64, detail int) (*Geometry, error) {
+	if err := checkSegments(detail); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("DodecahedronGeometry", js.Value(radius), js.Value(detail)), nil
+}
+
+// NewOctahedronGeometry creates geometry for an octahedron (8 sides).
+// A detail above 0 makes it more like a sphere.
+func (s *Scene) NewOctahedronGeometry(radius float64, detail int) (*Geometry, error) {
+	if err := checkSegments(detail); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("OctahedronGeometry", js.Value(radius), js.Value(detail)), nil
+}
+
+// NewLatheGeometry creates geometry by rotating the given 2D points around the Y axis,
+// like a vase on a lathe. X is the distance from the axis.
+func (s *Scene) NewLatheGeometry(points []*tinysvg.Pos, segments int) (*Geometry, error) {
+	if len(points) < 2 {
+		return nil, errors.New("a lathe needs at least 2 points")
+	}
+	if err := checkSegments(segments); err != nil {
+		return nil, err
+	}
+	return s.newGeometry("LatheGeometry", vector2s(points), js.Value(segments)), nil
+}
+
+// NewTubeGeometry creates geometry for a tube that follows a smooth curve through the given points
+func (s *Scene) NewTubeGeometry(path []Vector3, tubularSegments int, radius float64, radialSegments int, closed bool) (*Geometry, error) {
+	if len(path) < 2 {
+		return nil, errors.New("a tube needs at least 2 points")
+	}
+	if err := checkSegments(tubularSegments, radialSegments); err != nil {
+		return nil, err
+	}
+	curve := js.New(threeClass("CatmullRomCurve3"), vector3s(path), js.Value(closed))
+	return s.newGeometry("TubeGeometry", curve, js.Value(tubularSegments), js.Value(radius), js.Value(radialSegments), js.Value(closed)), nil
+}
+
+// typedArray returns an expression that decodes the given base64 data into a typed array,
+// like a Float32Array
+func typedArray(class string, data []byte) js.Expr {
+	decode := js.Raw("Uint8Array.from(atob(" + js.String(base64.StdEncoding.EncodeToString(data)).String() +
+		"), function(c) { return c.charCodeAt(0); }).buffer")
+	return js.New(js.Ident(class), decode)
+}
+
+// float32Bytes encodes the given numbers as little endian float32 values, like a Float32Array
+func float32Bytes(xs []float32) []byte {
+	data := make([]byte, 4*len(xs))
+	for i, x := range xs {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(x))
+	}
+	return data
+}
+
+// indexArray returns the given indices as a Uint16Array if they are small enough, or else as a Uint32Array
+func indexArray(indices []uint32, vertices int) js.Expr {
+	if vertices <= math.MaxUint16+1 {
+		data := make([]byte, 2*len(indices))
+		for i, index := range indices {
+			binary.LittleEndian.PutUint16(data[2*i:], uint16(index))
+		}
+		return typedArray("Uint16Array", data)
+	}
+	data := make([]byte, 4*len(indices))
+	for i, index := range indices {
+		binary.LittleEndian.PutUint32(data[4*i:], index)
+	}
+	return typedArray("Uint32Array", data)
+}
+
+// NewBufferGeometry creates geometry from vertex data. positions has x, y and z for each vertex.
+// normals (x, y, z) and uvs (u, v) are optional, and normals are computed if they are not given.
+// indices are optional, and have three vertex indices for each triangle. If there are no indices,
+// every three vertices make a triangle. The data is sent as compact, base64 encoded typed arrays.
+func (s *Scene) NewBufferGeometry(positions, normals, uvs []float32, indices []uint32) (*Geometry, error) {
+	if len(positions) == 0 || len(positions)%3 != 0 {
+		return nil, fmt.Errorf("expected x, y and z for each vertex, got %d numbers", len(positions))
+	}
+	vertices := len(positions) / 3
+	if len(normals) != 0 && len(normals) != len(positions) {
+		return nil, fmt.Errorf("expected %d numbers for the normals, got %d", len(positions), len(normals))
+	}
+	if len(uvs) != 0 && len(uvs) != 2*vertices {
+		return nil, fmt.Errorf("expected %d numbers for the uvs, got %d", 2*vertices, len(uvs))
+	}
+	if len(indices)%3 != 0 {
+		return nil, fmt.Errorf("expected three indices for each triangle, got %d indices", len(indices))
+	}
+	for _, index := range indices {
+		if index >= uint32(vertices) {
+			return nil, fmt.Errorf("index %d is out of range, there are %d vertices", index, vertices)
+		}
+	}
+	id := s.nextID(geometryPrefix)
+	code := newThree(id, "BufferGeometry")
+	attribute := func(name string, xs []float32, size int) string {
+		return call(id, "setAttribute", js.String(name), js.New(threeClass("BufferAttribute"), typedArray("Float32Array", float32Bytes(xs)), js.Value(size)))
+	}
+	code += attribute("position", positions, 3)
+	if len(normals) > 0 {
+		code += attribute("normal", normals, 3)
+	}
+	if len(uvs) > 0 {
+		code += attribute("uv", uvs, 2)
+	}
+	if len(indices) > 0 {
+		code += call(id, "setIndex", js.New(threeClass("BufferAttribute"), indexArray(indices, vertices), js.Value(1)))
+	}
+	if len(normals) == 0 {
+		code += call(id, "computeVertexNormals")
+	}
+	return s.addGeometry(&Geometry{ID: id, JS: code}), nil
+}
+
+// NewTorusGeometry creates geometry for a torus (a donut)
+func NewTorusGeometry(radius, tube float64, radialSegments, tubularSegments int) (*Geometry, error) {
+	return defaultScene.NewTorusGeometry(radius, tube, radialSegments, tubularSegments)
+}
+
+// NewTorusKnotGeometry creates geometry for a torus knot. p and q are how many times
+// the knot winds around its axis of rotational symmetry and around the interior of the torus.
+func NewTorusKnotGeometry(radius, tube float64, tubularSegments, radialSegments, p, q int) (*Geometry, error) {
+	return defaultScene.NewTorusKnotGeometry(radius, tube, tubularSegments, radialSegments, p, q)
+}
+
+// NewConeGeometry creates geometry for a cone
+func NewConeGeometry(radius, height float64, radialSegments int) (*Geometry, error) {
+	return defaultScene.NewConeGeometry(radius, height, radialSegments)
+}
+
+// NewRingGeometry creates geometry for a flat ring
+func NewRingGeometry(innerRadius, outerRadius float64, thetaSegments int) (*Geometry, error) {
+	return defaultScene.NewRingGeometry(innerRadius, outerRadius, thetaSegments)
+}
+
+// NewCircleGeometry creates geometry for a flat circle
+func NewCircleGeometry(radius float64, segments int) (*Geometry, error) {
+	return defaultScene.NewCircleGeometry(radius, segments)
+}
+
+// NewCapsuleGeometry creates geometry for a capsule, a cylinder with half spheres at the ends
+func NewCapsuleGeometry(radius, length float64, capSegments, radialSegments int) (*Geometry, error) {
+	return defaultScene.NewCapsuleGeometry(radius, length, capSegments, radialSegments)
+}
+
+// NewIcosahedronGeometry creates geometry for an icosahedron (20 sides)
+func NewIcosahedronGeometry(radius float64, detail int) (*Geometry, error) {
+	return defaultScene.NewIcosahedronGeometry(radius, detail)
+}
+
+// NewDodecahedronGeometry creates geometry for a dodecahedron (12 sides)
+func NewDodecahedronGeometry(radius float64, detail int) (*Geometry, error) {
+	return defaultScene.NewDodecahedronGeometry(radius, detail)
+}
+
+// NewOctahedronGeometry creates geometry for an octahedron (8 sides)
+func NewOctahedronGeometry(radius float64, detail int) (*Geometry, error) {
+	return defaultScene.NewOctahedronGeometry(radius, detail)
+}
+
+// NewLatheGeometry creates geometry by rotating the given 2D points around the Y axis
+func NewLatheGeometry(points []*tinysvg.Pos, segments int) (*Geometry, error) {
+	return defaultScene.NewLatheGeometry(points, segments)
+}
+
+// NewTubeGeometry creates geometry for a tube that follows a smooth curve through the given points
+func NewTubeGeometry(path []Vector3, tubularSegments int, radius float64, radialSegments int, closed bool) (*Geometry, error) {
+	return defaultScene.NewTubeGeometry(path, tubularSegments, radius, radialSegments, closed)
+}
+
+// NewBufferGeometry creates geometry from vertex data, see Scene.NewBufferGeometry
+func NewBufferGeometry(positions, normals, uvs []float32, indices []uint32) (*Geometry, error) {
+	return defaultScene.NewBufferGeometry(positions, normals, uvs, indices)
+}
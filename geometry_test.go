@@ -0,0 +1,77 @@
+package onthefly
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/xyproto/tinysvg"
+)
+
+func TestGeometryCatalogue(t *testing.T) {
+	scene := NewScene()
+	torus, _ := scene.NewTorusGeometry(1, 0.4, 12, 48)
+	knot, _ := scene.NewTorusKnotGeometry(1, 0.3, 64, 8, 2, 3)
+	lathe, _ := scene.NewLatheGeometry([]*tinysvg.Pos{tinysvg.NewPosf(0, 0), tinysvg.NewPosf(1, 0.5)}, 12)
+	tube, _ := scene.NewTubeGeometry([]Vector3{{0, 0, 0}, {1, 1, 0}, {2, 0, 1}}, 20, 0.1, 6, false)
+	for expected, g := range map[string]*Geometry{
+		"var g0 = new THREE.TorusGeometry(1, 0.4, 12, 48);":                                           torus,
+		"var g1 = new THREE.TorusKnotGeometry(1, 0.3, 64, 8, 2, 3);":                                  knot,
+		"var g2 = new THREE.LatheGeometry([new THREE.Vector2(0, 0), new THREE.Vector2(1, 0.5)], 12);": lathe,
+		"var g3 = new THREE.TubeGeometry(new THREE.CatmullRomCurve3([new THREE.Vector3(0, 0, 0), new THREE.Vector3(1, 1, 0), new THREE.Vector3(2, 0, 1)], false), 20, 0.1, 6, false);": tube,
+	} {
+		if g == nil || g.JS != expected {
+			t.Errorf("Expected:\n%s\nGot:\n%v", expected, g)
+		}
+	}
+	if len(scene.Geometries()) != 4 {
+		t.Errorf("Expected 4 geometries, got %d", len(scene.Geometries()))
+	}
+	if _, err := scene.NewIcosahedronGeometry(1, -1); err == nil {
+		t.Error("Expected an error for a negative detail")
+	}
+	if _, err := scene.NewLatheGeometry(nil, 12); err == nil {
+		t.Error("Expected an error for a lathe without points")
+	}
+}
+
+func TestBufferGeometry(t *testing.T) {
+	positions := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0, 1, 1, 0}
+	uvs := []float32{0, 0, 1, 0, 0, 1, 1, 1}
+	indices := []uint32{0, 1, 2, 2, 1, 3}
+	g, err := NewScene().NewBufferGeometry(positions, nil, uvs, indices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1.0 as a little endian float32 is 00 00 80 3f
+	encoded := base64.StdEncoding.EncodeToString(float32Bytes(positions))
+	for _, expected := range []string{
+		"var g0 = new THREE.BufferGeometry();",
+		`g0.setAttribute("position", new THREE.BufferAttribute(new Float32Array(Uint8Array.from(atob("` + encoded + `")`,
+		`g0.setAttribute("uv", `,
+		"g0.setIndex(new THREE.BufferAttribute(new Uint16Array(",
+		"g0.computeVertexNormals();",
+	} {
+		if !strings.Contains(g.JS, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, g.JS)
+		}
+	}
+	if b := float32Bytes([]float32{1}); string(b) != "\x00\x00\x80\x3f" {
+		t.Errorf("Unexpected float32 encoding: %x", b)
+	}
+
+	for _, bad := range []struct {
+		positions, normals, uvs []float32
+		indices                 []uint32
+	}{
+		{positions: []float32{0, 0}},
+		{positions: positions, normals: []float32{0, 0, 1}},
+		{positions: positions, uvs: []float32{0, 0}},
+		{positions: positions, indices: []uint32{0, 1}},
+		{positions: positions, indices: []uint32{0, 1, 4}},
+	} {
+		if _, err := NewBufferGeometry(bad.positions, bad.normals, bad.uvs, bad.indices); err == nil {
+			t.Errorf("Expected an error for %+v", bad)
+		}
+	}
+}
@@ -1,6 +1,17 @@
 package onthefly
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// jqueryID is the ID of the script tag that includes jQuery
+const jqueryID = "onthefly-jquery"
 
 // LinkToJS links a page up with a JS file
 // Takes the url to a JS file as a string
@@ -44,7 +55,9 @@ func AddScriptToHeader(page *Page, js string) error {
 	return err
 }
 
-// AddScriptToHead adds javascript code in a script tag in the head tag
+// AddScriptToHead adds javascript code in a script tag in the head tag.
+// Any "</script" or "<!--" in the code is escaped, so that it does not end the tag early.
+// jQuery is not included automatically, see UseJQuery.
 func (page *Page) AddScriptToHead(js string) (*Tag, error) {
 	// Check if there's anything to add
 	if js == "" {
@@ -57,11 +70,13 @@ func (page *Page) AddScriptToHead(js string) (*Tag, error) {
 	}
 	script := head.AddNewTag("script")
 	script.AddAttrib("type", "text/javascript")
-	script.AddContent(js)
+	script.AddContent(escapeScript(js))
 	return script, nil
 }
 
-// AddScriptToBody adds javascript code in a script tag at the end of the body tag
+// AddScriptToBody adds javascript code in a script tag at the end of the body tag.
+// Any "</script" or "<!--" in the code is escaped, so that it does not end the tag early.
+// jQuery is not included automatically, see UseJQuery.
 func (page *Page) AddScriptToBody(js string) (*Tag, error) {
 	// Check if there's anything to add
 	if js == "" {
@@ -74,6 +89,104 @@ func (page *Page) AddScriptToBody(js string) (*Tag, error) {
 	}
 	script := body.AddNewTag("script")
 	script.AddAttrib("type", "text/javascript")
-	script.AddContent(js)
+	script.AddContent(escapeScript(js))
+	return script, nil
+}
+
+// hasJQuery checks if the page already includes or links to jQuery
+func (page *Page) hasJQuery() bool {
+	if page.root.FindChildByAttribute("id", jqueryID) != nil {
+		return true
+	}
+	var found bool
+	var walk func(tag *Tag)
+	walk = func(tag *Tag) {
+		for _, child := range tag.GetChildren() {
+			if child.name == "script" && strings.Contains(strings.ToLower(child.attrs["src"]), "jquery") {
+				found = true
+				return
+			}
+			walk(child)
+		}
+	}
+	walk(page.root)
+	return found
+}
+
+// addJQuery adds the given jQuery script tag to the head, before any other script tags
+func (page *Page) addJQuery(script *Tag) error {
+	if page.hasJQuery() {
+		return nil
+	}
+	head, err := page.GetTag("head")
+	if err != nil {
+		return err
+	}
+	script.AddAttrib("id", jqueryID)
+	script.AddAttrib("type", "text/javascript")
+	children := head.GetChildren()
+	i := 0
+	for i < len(children) && children[i].name != "script" {
+		i++
+	}
+	head.setChildren(append(children[:i:i], append([]*Tag{script}, children[i:]...)...))
+	return nil
+}
+
+// UseJQuery makes sure that the page includes the embedded jQuery, in the head,
+// before any other script tags. It is only added once, and not at all if the page
+// already links to jQuery. This must be called for pages that use the jQuery helpers,
+// like OnDocumentReady and ShowAnimatedIf, except that AddScriptOnReady does it
+// automatically when the page uses the JQueryDOM backend.
+func (page *Page) UseJQuery() error {
+	script := NewTag("script")
+	script.AddContent(jqueryJS)
+	return page.addJQuery(script)
+}
+
+// UseJQueryFrom makes sure that the page links to jQuery at the given URL, in the head,
+// before any other script tags. ServeJQuery can be used for serving the embedded jQuery.
+// The link is only added once, and not at all if the page already includes jQuery.
+func (page *Page) UseJQueryFrom(jqueryURL string) error {
+	script := NewTag("script")
+	script.AddAttrib("src", jqueryURL)
+	script.AddContent(" ")
+	return page.addJQuery(script)
+}
+
+// ServeJQuery serves the embedded jQuery at the given URL
+func ServeJQuery(mux *http.ServeMux, jqueryURL string) {
+	mux.HandleFunc(jqueryURL, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("Content-Type", "text/javascript")
+		w.Write([]byte(jqueryJS))
+	})
+}
+
+// EmbedJSON adds the given value as JSON in a <script type="application/json"> tag in the head,
+// with the given ID, so that client scripts can read it, for instance as initial state for
+// AngularJS or Three.js. "<", ">" and "&" are escaped, so that the JSON can not end the tag early.
+// If there already is embedded JSON with the same ID, it is replaced. See also ReadJSON.
+func (page *Page) EmbedJSON(id string, v any) (*Tag, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	head, err := page.GetTag("head")
+	if err != nil {
+		return nil, err
+	}
+	script := head.FindChildByAttribute("id", html.EscapeString(id))
+	if script == nil || script.name != "script" {
+		script = head.AddNewTag("script")
+		script.AddAttrib("id", html.EscapeString(id))
+		script.AddAttrib("type", "application/json")
+	}
+	script.SetContent(string(data))
 	return script, nil
 }
+
+// ReadJSON returns a JavaScript expression that reads the JSON that was embedded with EmbedJSON
+func ReadJSON(id string) js.Expr {
+	element := js.Method(js.Ident("document"), "getElementById", js.String(id))
+	return js.Call(js.Ident("JSON.parse"), js.Member(element, "textContent"))
+}
@@ -0,0 +1,54 @@
+package onthefly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+func TestEmbedJSON(t *testing.T) {
+	page := NewAngularPage("State")
+	state := map[string]any{"title": "</script><script>alert(1)</script>", "items": []int{1, 2, 3}}
+	script, err := page.EmbedJSON("initial-state", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := script.GetAttribute("type"); typ != "application/json" {
+		t.Errorf("Expected a JSON script tag, got type %q", typ)
+	}
+	s := page.String()
+	if strings.Contains(s, "</script><script>alert") || strings.Contains(s, "alert(1)</script>") {
+		t.Errorf("Expected the JSON to be escaped:\n%s", script)
+	}
+	expected := `{"items":[1,2,3],"title":"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e"}`
+	if script.GetContent() != expected {
+		t.Errorf("Expected %s, got %s", expected, script.GetContent())
+	}
+
+	// Embedding again with the same ID replaces the data
+	if _, err := page.EmbedJSON("initial-state", []string{"a & b"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(page.String(), `id="initial-state"`); n != 1 {
+		t.Errorf("Expected one JSON script tag, got %d", n)
+	}
+	if !strings.Contains(page.String(), `["a \u0026 b"]`) {
+		t.Errorf("Expected the data to be replaced:\n%s", page)
+	}
+
+	if _, err := page.EmbedJSON("bad", func() {}); err == nil {
+		t.Error("Expected an error for a value that can not be encoded as JSON")
+	}
+}
+
+func TestEmbedJSONThreeJS(t *testing.T) {
+	page, script := NewThreeJS("Scene")
+	if _, err := page.EmbedJSON("scene-data", map[string]float64{"size": 2}); err != nil {
+		t.Fatal(err)
+	}
+	script.AddStmt(js.Var("data", ReadJSON("scene-data")))
+	if s := page.String(); !strings.Contains(s, `var data = JSON.parse(document.getElementById("scene-data").textContent);`) {
+		t.Errorf("Expected the embedded data to be read:\n%s", s)
+	}
+}
@@ -1,140 +1,253 @@
 package onthefly
 
-// Various JavaScript and JQuery functions
+import (
+	"regexp"
 
-// fn returns JavaScript code wrapped in an anonymous function
-func fn(source string) string {
-	return "function() { " + source + " }"
+	"github.com/xyproto/onthefly/js"
+)
+
+// Various JavaScript and JQuery functions.
+// The functions that return strings are wrappers around the typed functions
+// and methods that return js.Stmt, which can be combined with the js package.
+
+// scriptEndPattern matches sequences that would end or confuse a <script> tag
+var scriptEndPattern = regexp.MustCompile(`(?i)</(script)|<!--`)
+
+// JQuery represents a jQuery selection, like $("#id")
+type JQuery struct {
+	js.Expr
 }
 
-// quote quotes the given string in a simple way, by wrapping it in double quotes
+// Select returns a jQuery selection for the given selector
+func Select(selector string) JQuery {
+	return JQuery{js.Call(js.Ident("$"), js.String(selector))}
+}
+
+// quote returns the given string as a double quoted JavaScript string literal.
+// Quotes, backslashes, newlines and other control characters are escaped, and so are
+// "<", ">" and "&", so that the literal can not end a <script> tag.
 func quote(src string) string {
-	return "\"" + src + "\""
+	return js.String(src).String()
+}
+
+// escapeScript makes sure that the given JavaScript code can be placed inside a
+// <script> tag without ending it early, by escaping "</script" as "<\/script"
+// and "<!--" as "<\!--". Both are equivalent inside JavaScript strings and regular expressions.
+func escapeScript(source string) string {
+	return scriptEndPattern.ReplaceAllStringFunc(source, func(m string) string {
+		return "<\\" + m[1:]
+	})
+}
+
+// Call returns a statement that calls the given jQuery method on the selection
+func (q JQuery) Call(methodname string, args ...js.Expr) js.Stmt {
+	return js.ExprStmt(js.Method(q, methodname, args...))
+}
+
+// On returns a statement that runs the given statements at the given event, for example "click"
+func (q JQuery) On(event string, body ...js.Stmt) js.Stmt {
+	return q.Call(event, js.Fn(body...))
+}
+
+// Hide returns a statement that hides the selection
+func (q JQuery) Hide() js.Stmt {
+	return q.Call("hide")
+}
+
+// HideAnimated returns a statement that hides the selection in an animated way
+func (q JQuery) HideAnimated() js.Stmt {
+	return q.Call("hide", js.String("normal")) // "fast", "normal", "slow" or milliseconds
+}
+
+// Show returns a statement that shows the selection
+func (q JQuery) Show() js.Stmt {
+	return q.Call("show")
+}
+
+// ShowAnimated returns a statement that shows the selection in an animated way
+func (q JQuery) ShowAnimated() js.Stmt {
+	return q.Call("show", js.String("normal")) // "fast", "normal", "slow" or milliseconds
+}
+
+// ShowInline returns a statement that styles the selection with "display:inline"
+func (q JQuery) ShowInline() js.Stmt {
+	return q.Call("css", js.String("display"), js.String("inline"))
 }
 
-// event returns JavaScript code that runs the given JavaScript code at the
-// given event on the given tag. For example the "click" event.
-func event(tagname, event, source string) string {
-	return "$(" + quote(tagname) + ")." + event + "(" + fn(source) + ");"
+// Focus returns a statement that sets focus on the selection
+func (q JQuery) Focus() js.Stmt {
+	return q.Call("focus")
 }
 
-// method returns JavaScript code that calls a given method on a given
-// tag, with the given string value as the argument.
-// The value is used raw and not quoted.
-func method(tagname, methodname, value string) string {
-	return "$(" + quote(tagname) + ")." + methodname + "(" + value + ");"
+// SetText returns a statement that sets the text of the selection
+func (q JQuery) SetText(text string) js.Stmt {
+	return q.Call("text", js.String(text))
 }
 
-// methodString returns JavaScript code that calls a given method on a given
-// tag, with the given string value as the argument
-// The value is quoted.
-func methodString(tagname, methodname, value string) string {
-	return method(tagname, methodname, quote(value))
+// SetHTML returns a statement that sets the HTML of the selection to the given expression
+func (q JQuery) SetHTML(html js.Expr) js.Stmt {
+	return q.Call("html", html)
+}
+
+// SetValue returns a statement that sets the value of the selection to the given expression
+func (q JQuery) SetValue(val js.Expr) js.Stmt {
+	return q.Call("val", val)
+}
+
+// Load returns a statement that loads the contents of the given URL into the selection
+func (q JQuery) Load(url string) js.Stmt {
+	return q.Call("load", js.String(url))
+}
+
+// OnDocumentReadyStmt returns a statement that runs the given statements when the HTML document is ready
+func OnDocumentReadyStmt(body ...js.Stmt) js.Stmt {
+	return js.ExprStmt(js.Method(js.Call(js.Ident("$"), js.Ident("document")), "ready", js.Fn(body...)))
+}
+
+// AlertStmt returns a statement that displays a message box
+func AlertStmt(msg string) js.Stmt {
+	return js.ExprStmt(js.Call(js.Ident("alert"), js.String(msg)))
+}
+
+// RedirectStmt returns a statement that redirects to the given URL
+func RedirectStmt(URL string) js.Stmt {
+	return js.Assign(js.Ident("window.location.href"), js.String(URL))
+}
+
+// IfURLIsTrue returns a statement that runs the given statements if booleanURL returns "1"
+func IfURLIsTrue(booleanURL string, body ...js.Stmt) js.Stmt {
+	return ifURL(booleanURL, "==", body)
+}
+
+// IfURLIsNotTrue returns a statement that runs the given statements if booleanURL does not return "1"
+func IfURLIsNotTrue(booleanURL string, body ...js.Stmt) js.Stmt {
+	return ifURL(booleanURL, "!=", body)
+}
+
+// ifURL returns a statement that fetches booleanURL and compares the result with "1"
+func ifURL(booleanURL, comparison string, body []js.Stmt) js.Stmt {
+	condition := js.Raw("data " + comparison + " " + quote("1"))
+	callback := js.Func{Params: []string{"data"}, Body: []js.Stmt{js.If(condition, body...)}}
+	return js.ExprStmt(js.Call(js.Ident("$.get"), js.String(booleanURL), callback))
+}
+
+// ScrollDownAnimatedStmt returns a statement that will slowly scroll the page down
+func ScrollDownAnimatedStmt() js.Stmt {
+	height := js.Method(js.Call(js.Ident("$"), js.Ident("document")), "height")
+	return Select("html, body").Call("animate", js.Object{"scrollTop": height}, js.String("slow"))
 }
 
 // OnDocumentReady returns JavaScript code the runs the given JavaScript code when the HTML document is ready in the browser.
 func OnDocumentReady(source string) string {
-	return "$(document).ready(" + fn(source) + ");"
+	return OnDocumentReadyStmt(js.Stmt(source)).String()
 }
 
 // Alert returns JavaScript code that displays a pretty intruding message box. The "msg" will be quoted.
 func Alert(msg string) string {
-	return "alert(" + quote(msg) + ");"
+	return AlertStmt(msg).String()
 }
 
 // OnClick returns JavaScript code that runs the given JavaScript code when the given tag name is clicked on
 func OnClick(tagname, source string) string {
-	return event(tagname, "click", source)
+	return Select(tagname).On("click", js.Stmt(source)).String()
 }
 
 // SetText returns JavaScript code that sets the text of the given tag name
 func SetText(tagname, text string) string {
-	return methodString(tagname, "text", text)
+	return Select(tagname).SetText(text).String()
 }
 
 // SetHTML returns JavaScript code that sets the HTML of the given tag name
 func SetHTML(tagname, html string) string {
-	return method(tagname, "html", html)
+	return Select(tagname).SetHTML(js.Raw(html)).String()
 }
 
 // SetValue returns JavaScript code that quotes and then sets the contents of the given tag name
 func SetValue(tagname, val string) string {
-	return methodString(tagname, "val", val)
+	return Select(tagname).SetValue(js.String(val)).String()
 }
 
 // SetRawValue returns JavaScript code that sets the contents of a given tag name, without quoting
 func SetRawValue(tagname, val string) string {
-	return method(tagname, "val", val)
+	return Select(tagname).SetValue(js.Raw(val)).String()
 }
 
 // Hide returns JavaScript code that hides the given tag name
 func Hide(tagname string) string {
-	return "$(" + quote(tagname) + ").hide();"
+	return Select(tagname).Hide().String()
 }
 
 // HideAnimated returns JavaScript code that hides the given tag name in an animated way
 func HideAnimated(tagname string) string {
-	return "$(" + quote(tagname) + ").hide('normal');" // 'fast', 'normal', 'slow' or milliseconds
+	return Select(tagname).HideAnimated().String()
 }
 
 // Show returns JavaScript code that shows the given tag name
 func Show(tagname string) string {
-	return "$(" + quote(tagname) + ").show();"
+	return Select(tagname).Show().String()
 }
 
 // Focus returns JavaScript code that sets focus on the given tag name
 func Focus(tagname string) string {
-	return "$(" + quote(tagname) + ").focus();"
+	return Select(tagname).Focus().String()
 }
 
 // ShowAnimated returns JavaScript code that displays the given tag name in an animated way
 func ShowAnimated(tagname string) string {
-	return "$(" + quote(tagname) + ").show('normal');" // 'fast', 'normal', 'slow' or milliseconds
+	return Select(tagname).ShowAnimated().String()
 }
 
 // ShowInline returns JavaScript code that styles the given tag with "display:inline"
 func ShowInline(tagname string) string {
-	return "$(" + quote(tagname) + ").css('display', 'inline');"
+	return Select(tagname).ShowInline().String()
 }
 
 // ShowInlineAnimated returns JavaScript code that show the given tag with "display:inline", then hides it and then shows it in an animated way
 func ShowInlineAnimated(tagname string) string {
-	return ShowInline(tagname) + Hide(tagname) + ShowAnimated(tagname)
+	q := Select(tagname)
+	return js.Join(q.ShowInline(), q.Hide(), q.ShowAnimated())
 }
 
 // ShowInlineAnimatedIf returns JavaScript that shows the given tag in an animated way if the value from the given URL is "1"
 func ShowInlineAnimatedIf(booleanURL, tagname string) string {
-	return "$.get(" + quote(booleanURL) + ", function(data) { if (data == \"1\") {" + ShowInlineAnimated(tagname) + "}; });"
+	q := Select(tagname)
+	return IfURLIsTrue(booleanURL, q.ShowInline(), q.Hide(), q.ShowAnimated()).String()
 }
 
 // Load returns JavaScript that loads the contents of the given URL into the given tag name
 func Load(tagname, url string) string {
-	return methodString(tagname, "load", url)
+	return Select(tagname).Load(url).String()
 }
 
 // HideIfNot returns JavaScript that will hide a tag if booleanURL doesn't return "1"
 func HideIfNot(booleanURL, tagname string) string {
-	return "$.get(" + quote(booleanURL) + ", function(data) { if (data != \"1\") {" + Hide(tagname) + "}; });"
+	return IfURLIsNotTrue(booleanURL, Select(tagname).Hide()).String()
 }
 
 // ShowAnimatedIf returns JavaScript that will show a tag if booleanURL returns "1"
 func ShowAnimatedIf(booleanURL, tagname string) string {
-	return "$.get(" + quote(booleanURL) + ", function(data) { if (data == \"1\") {" + ShowAnimated(tagname) + "}; });"
+	return IfURLIsTrue(booleanURL, Select(tagname).ShowAnimated()).String()
 }
 
 // ScrollDownAnimated returns JavaScript code that will slowly scroll the page down
 func ScrollDownAnimated() string {
-	return "$('html, body').animate({scrollTop:$(document).height()}, 'slow');"
+	return ScrollDownAnimatedStmt().String()
 }
 
-// JS wraps JavaScript code in a <script> tag
+// JS wraps JavaScript code in a <script> tag.
+// Any "</script" in the code is escaped, so that it does not end the tag early.
 func JS(source string) string {
 	if source != "" {
-		return "<script type=\"text/javascript\">" + source + "</script>"
+		return "<script type=\"text/javascript\">" + escapeScript(source) + "</script>"
 	}
 	return ""
 }
 
+// Script wraps the given statements in a <script> tag
+func Script(stmts ...js.Stmt) string {
+	return JS(js.Join(stmts...))
+}
+
 // DocumentReadyJS returns HTML that will run the given JavaScript code once the document is ready.
 // Returns an empty string if there is no JavaScript code to run.
 func DocumentReadyJS(source string) string {
@@ -146,5 +259,5 @@ func DocumentReadyJS(source string) string {
 
 // Redirect returns JavaScript code that redirects to the given URL
 func Redirect(URL string) string {
-	return "window.location.href = \"" + URL + "\";"
+	return RedirectStmt(URL).String()
 }
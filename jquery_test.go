@@ -0,0 +1,168 @@
+package onthefly
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{"", `""`},
+		{"hello", `"hello"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`back\slash`, `"back\\slash"`},
+		{"two\nlines\r\n", `"two\nlines\r\n"`},
+		{"</script><script>alert(1)</script>", `"\u003c/script\u003e\u003cscript\u003ealert(1)\u003c/script\u003e"`},
+		{"it's & <!--", `"it's \u0026 \u003c!--"`},
+		{"\u2028\u2029\x00\x1f", `"\u2028\u2029\u0000\u001f"`},
+		{"blåbærsyltetøy", `"blåbærsyltetøy"`},
+	}
+	for _, test := range tests {
+		if got := quote(test.input); got != test.expected {
+			t.Errorf("quote(%q): expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestHostileHelperInput(t *testing.T) {
+	hostile := []string{
+		`"); alert(1); ("`,
+		`\"); alert(1); //`,
+		"line\nbreak\"); alert(1); //",
+		"</script><script>alert(1)</script>",
+		"'); alert(1); ('",
+	}
+	for _, input := range hostile {
+		for _, js := range []string{
+			Alert(input),
+			SetText(input, input),
+			SetValue(input, input),
+			Load(input, input),
+			Redirect(input),
+			Hide(input),
+			Show(input),
+			OnClick(input, ""),
+			HideIfNot(input, input),
+			ShowAnimatedIf(input, input),
+			ShowInlineAnimatedIf(input, input),
+		} {
+			code, ok := stripStringLiterals(js)
+			if !ok || strings.Contains(code, "alert(1)") {
+				t.Errorf("Input %q was able to inject code: %s", input, js)
+			}
+			if strings.Contains(strings.ToLower(js), "</script") || strings.Contains(js, "\n") {
+				t.Errorf("Input %q is not escaped properly: %s", input, js)
+			}
+		}
+	}
+	if got := Alert(`"); alert(1); ("`); got != `alert("\"); alert(1); (\"");` {
+		t.Errorf("Unexpected alert code: %s", got)
+	}
+}
+
+func TestJSScriptEnd(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{"var s = '</script>';", `<script type="text/javascript">var s = '<\/script>';</script>`},
+		{"var s = '</SCRIPT >';", `<script type="text/javascript">var s = '<\/SCRIPT >';</script>`},
+		{"var s = '<!--';", `<script type="text/javascript">var s = '<\!--';</script>`},
+		{"var s = '</b>';", `<script type="text/javascript">var s = '</b>';</script>`},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := JS(test.input); got != test.expected {
+			t.Errorf("JS(%q): expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+}
+
+// stripStringLiterals removes all string literals from the given JavaScript code.
+// Returns false if a string literal is not terminated.
+func stripStringLiterals(js string) (string, bool) {
+	var sb strings.Builder
+	for i := 0; i < len(js); i++ {
+		c := js[i]
+		if c != '"' && c != '\'' {
+			sb.WriteByte(c)
+			continue
+		}
+		terminated := false
+		for i++; i < len(js); i++ {
+			if js[i] == '\\' {
+				i++
+				continue
+			}
+			if js[i] == '\n' {
+				return "", false
+			}
+			if js[i] == c {
+				terminated = true
+				break
+			}
+		}
+		if !terminated {
+			return "", false
+		}
+		sb.WriteString("\"\"")
+	}
+	return sb.String(), true
+}
+
+func TestUseJQuery(t *testing.T) {
+	page := NewHTML5Page("jQuery")
+	if _, err := page.AddScriptToHead("var x = 1;"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := page.AddScriptToBody(OnDocumentReady(Hide("#a"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := page.AddScriptToHead("// $(x) and \"jQuery\" are only mentioned here"); err != nil {
+		t.Fatal(err)
+	}
+	if page.hasJQuery() {
+		t.Error("Did not expect jQuery to be included without UseJQuery")
+	}
+	if err := page.UseJQuery(); err != nil {
+		t.Fatal(err)
+	}
+	if err := page.UseJQuery(); err != nil {
+		t.Fatal(err)
+	}
+	head, _ := page.GetTag("head")
+	var scripts []*Tag
+	for _, child := range head.GetChildren() {
+		if child.GetName() == "script" {
+			scripts = append(scripts, child)
+		}
+	}
+	if len(scripts) != 3 {
+		t.Fatalf("Expected jQuery and two scripts in the head, got %d script tags", len(scripts))
+	}
+	if id, _ := scripts[0].GetAttribute("id"); id != jqueryID {
+		t.Error("Expected jQuery to be included before the other scripts")
+	}
+	if strings.Count(page.String(), "jQuery v") != 1 {
+		t.Error("Expected jQuery to be included exactly once")
+	}
+
+	// A page that already links to jQuery should not get another copy
+	page = NewHTML5Page("Linked")
+	page.LinkToJS("/js/jquery-3.6.1.min.js")
+	if err := page.UseJQuery(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(page.String(), "jQuery v") {
+		t.Error("Expected the linked jQuery to be used")
+	}
+
+	page = NewHTML5Page("From URL")
+	if err := page.UseJQueryFrom("/jquery.min.js"); err != nil {
+		t.Fatal(err)
+	}
+	if script := page.GetRoot().FindChildByAttribute("id", jqueryID); script == nil || !script.HasAttribute("src") {
+		t.Error("Expected a link to jQuery")
+	}
+}
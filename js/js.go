@@ -0,0 +1,251 @@
+// Package js can generate JavaScript code from typed values, so that code and
+// data are kept apart. Data is always encoded with Value, which uses JSON and
+// escapes characters that could end a <script> tag, while code is built from
+// expressions and statements. The generated code is deterministic, for example
+// the keys of an Object are always sorted.
+package js
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type (
+	// Expr is a JavaScript expression
+	Expr interface {
+		String() string
+		expr()
+	}
+	// Stmt is a JavaScript statement, including the trailing semicolon
+	Stmt string
+	// Raw is JavaScript code that is used as it is
+	Raw string
+	// Func is an anonymous JavaScript function
+	Func struct {
+		Params []string
+		Body   []Stmt
+	}
+	// Object is a JavaScript object literal. The keys are sorted when rendered.
+	Object map[string]Expr
+	// Array is a JavaScript array literal
+	Array []Expr
+	// BinaryExpr is a binary operation, like "a + b"
+	BinaryExpr struct {
+		Left  Expr
+		Op    string
+		Right Expr
+	}
+)
+
+// This refers to the object that a function is called on, like the element in an event handler
+const This Raw = "this"
+
+// identifierPattern matches keys that do not need to be quoted in object literals
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+func (Raw) expr()        {}
+func (Func) expr()       {}
+func (Object) expr()     {}
+func (Array) expr()      {}
+func (BinaryExpr) expr() {}
+
+// String returns the code
+func (r Raw) String() string {
+	return string(r)
+}
+
+// String returns the code for the statement
+func (s Stmt) String() string {
+	return string(s)
+}
+
+// String returns the code for the function
+func (f Func) String() string {
+	return "function(" + strings.Join(f.Params, ", ") + ") { " + Join(f.Body...) + " }"
+}
+
+// String returns the code for the object literal
+func (o Object) String() string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		name := key
+		if !identifierPattern.MatchString(key) {
+			name = String(key).String()
+		}
+		parts[i] = name + ": " + o[key].String()
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// String returns the code for the array literal
+func (a Array) String() string {
+	return "[" + joinExprs(a) + "]"
+}
+
+// String returns the code for the operation. Operands that are also binary operations are
+// put in parentheses, except for the left operand when it has the same operator, since the
+// operations are then already evaluated from left to right, as in "a + b + c".
+func (b BinaryExpr) String() string {
+	left, right := b.Left.String(), b.Right.String()
+	if l, ok := b.Left.(BinaryExpr); ok && l.Op != b.Op {
+		left = "(" + left + ")"
+	}
+	if _, ok := b.Right.(BinaryExpr); ok {
+		right = "(" + right + ")"
+	}
+	return left + " " + b.Op + " " + right
+}
+
+// joinExprs returns the given expressions, separated by commas
+func joinExprs(exprs []Expr) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = e.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Value returns the given Go value as a JavaScript expression, by encoding it as JSON.
+// The characters "<", ">" and "&" are escaped, so that the value can be placed inside
+// of a <script> tag. NaN and infinite numbers become NaN, Infinity and -Infinity.
+// Value panics if the value can not be encoded, like a channel or a function, or a
+// struct that contains NaN. Use Encode for values that come from elsewhere.
+func Value(v any) Expr {
+	e, err := Encode(v)
+	if err != nil {
+		panic("js.Value: " + err.Error())
+	}
+	return e
+}
+
+// Encode returns the given Go value as a JavaScript expression, like Value,
+// but returns an error if the value can not be encoded as JSON
+func Encode(v any) (Expr, error) {
+	switch x := v.(type) {
+	case Expr:
+		return x, nil
+	case float64:
+		if e, ok := specialNumber(x); ok {
+			return e, nil
+		}
+	case float32:
+		if e, ok := specialNumber(float64(x)); ok {
+			return e, nil
+		}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Raw(data), nil
+}
+
+// specialNumber returns NaN, Infinity or -Infinity for numbers that JSON can not represent
+func specialNumber(x float64) (Expr, bool) {
+	switch {
+	case math.IsNaN(x):
+		return Raw("NaN"), true
+	case math.IsInf(x, 1):
+		return Raw("Infinity"), true
+	case math.IsInf(x, -1):
+		return Raw("-Infinity"), true
+	}
+	return nil, false
+}
+
+// String returns the given string as a JavaScript string literal
+func String(s string) Expr {
+	return Value(s)
+}
+
+// Ident returns a reference to a variable or another name, like "document" or "THREE.Mesh"
+func Ident(name string) Expr {
+	return Raw(name)
+}
+
+// Call returns an expression that calls the given function with the given arguments
+func Call(fn Expr, args ...Expr) Expr {
+	return Raw(fn.String() + "(" + joinExprs(args) + ")")
+}
+
+// Member returns an expression that accesses the given property of an object, like "obj.name"
+func Member(obj Expr, name string) Expr {
+	return Raw(obj.String() + "." + name)
+}
+
+// Index returns an expression that accesses an object with the given key, like "obj[key]"
+func Index(obj, key Expr) Expr {
+	return Raw(obj.String() + "[" + key.String() + "]")
+}
+
+// Method returns an expression that calls a method of an object, like "obj.name(args)"
+func Method(obj Expr, name string, args ...Expr) Expr {
+	return Call(Member(obj, name), args...)
+}
+
+// New returns an expression that creates a new object, like "new THREE.Scene()"
+func New(constructor Expr, args ...Expr) Expr {
+	return Raw("new " + constructor.String() + "(" + joinExprs(args) + ")")
+}
+
+// Var returns a statement that declares a variable, like "var x = 42;"
+func Var(name string, value Expr) Stmt {
+	return Stmt("var " + name + " = " + value.String() + ";")
+}
+
+// Assign returns a statement that assigns a value, like "x.y = 42;"
+func Assign(target, value Expr) Stmt {
+	return Stmt(target.String() + " = " + value.String() + ";")
+}
+
+// ExprStmt returns an expression as a statement, by adding a semicolon
+func ExprStmt(e Expr) Stmt {
+	return Stmt(e.String() + ";")
+}
+
+// Return returns a return statement
+func Return(e Expr) Stmt {
+	return Stmt("return " + e.String() + ";")
+}
+
+// If returns an if statement
+func If(condition Expr, body ...Stmt) Stmt {
+	return Stmt("if (" + condition.String() + ") { " + Join(body...) + " }")
+}
+
+// Join returns the code for the given statements, one after the other
+func Join(stmts ...Stmt) string {
+	var sb strings.Builder
+	for _, s := range stmts {
+		sb.WriteString(string(s))
+	}
+	return sb.String()
+}
+
+// Binary returns a binary operation, like "a + b" or "a * b"
+func Binary(left Expr, op string, right Expr) Expr {
+	return BinaryExpr{Left: left, Op: op, Right: right}
+}
+
+// Concat returns the given expressions added together, like "a + b + c",
+// for concatenating strings
+func Concat(first Expr, rest ...Expr) Expr {
+	e := first
+	for _, x := range rest {
+		e = Binary(e, "+", x)
+	}
+	return e
+}
+
+// Fn returns an anonymous function without parameters
+func Fn(body ...Stmt) Func {
+	return Func{Body: body}
+}
@@ -0,0 +1,73 @@
+package js
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{42, "42"},
+		{0.02, "0.02"},
+		{true, "true"},
+		{nil, "null"},
+		{"</script>", `"\u003c/script\u003e"`},
+		{"a \"quoted\"\nline", `"a \"quoted\"\nline"`},
+		{[]int{1, 2}, "[1,2]"},
+		{map[string]int{"b": 2, "a": 1}, `{"a":1,"b":2}`},
+		{Raw("x"), "x"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "Infinity"},
+		{float32(math.Inf(-1)), "-Infinity"},
+	}
+	for _, test := range tests {
+		if got := Value(test.value).String(); got != test.expected {
+			t.Errorf("Value(%#v): expected %s, got %s", test.value, test.expected, got)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{Var("x", Value(1)).String(), "var x = 1;"},
+		{ExprStmt(Call(Ident("alert"), String("hi"))).String(), `alert("hi");`},
+		{Method(Call(Ident("$"), String("#id")), "hide").String(), `$("#id").hide()`},
+		{New(Ident("THREE.Scene")).String(), "new THREE.Scene()"},
+		{Object{"b": Value(2), "a": Value(1), "data-x": Value("y")}.String(), `{a: 1, b: 2, "data-x": "y"}`},
+		{Array{Value(1), String("two")}.String(), `[1, "two"]`},
+		{Func{Params: []string{"e"}, Body: []Stmt{Return(Ident("e"))}}.String(), "function(e) { return e; }"},
+		{Fn().String(), "function() {  }"},
+		{If(Raw("a > b"), Assign(Ident("a"), Ident("b"))).String(), "if (a > b) { a = b; }"},
+		{Index(Ident("a"), Value(0)).String(), "a[0]"},
+		{Member(This, "href").String(), "this.href"},
+		{Concat(Member(This, "href"), String(" #t"), Ident("x")).String(), `this.href + " #t" + x`},
+		{Binary(Ident("a"), "*", Concat(Ident("b"), Ident("c"))).String(), "a * (b + c)"},
+		{Binary(Binary(Ident("a"), "+", Ident("b")), "*", Ident("c")).String(), "(a + b) * c"},
+		{Binary(Ident("a"), "-", Binary(Ident("b"), "-", Ident("c"))).String(), "a - (b - c)"},
+	}
+	for _, test := range tests {
+		if test.code != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, test.code)
+		}
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	for _, v := range []any{make(chan int), func() {}, []float64{math.NaN()}} {
+		if _, err := Encode(v); err == nil {
+			t.Errorf("Expected an error for %T", v)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Value to panic for a channel")
+		}
+	}()
+	Value(make(chan int))
+}
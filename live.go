@@ -0,0 +1,314 @@
+package onthefly
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// liveID is the ID of the script tag that connects a page to a LiveServer
+const liveID = "onthefly-live"
+
+// liveBuffer is how many updates can be queued for a browser, before it has to reconnect
+const liveBuffer = 64
+
+// liveScript receives updates and patches the elements by ID. The arguments are the URL
+// of the LiveServer, and true if a WebSocket should be used instead of Server-Sent Events.
+const liveScript = `(function(url, websocket) {
+	function apply(data) {
+		var update = JSON.parse(data);
+		var el = document.getElementById(update.id);
+		if (!el) {
+			return;
+		}
+		if ("content" in update) {
+			el.innerHTML = update.content;
+		}
+		Object.keys(update.attrs || {}).forEach(function(name) {
+			if (update.attrs[name] === null) {
+				el.removeAttribute(name);
+			} else {
+				el.setAttribute(name, update.attrs[name]);
+			}
+		});
+	}
+	if (!websocket) {
+		new EventSource(url).addEventListener("update", function(e) {
+			apply(e.data);
+		});
+		return;
+	}
+	function connect() {
+		var ws = new WebSocket((window.location.protocol == "https:" ? "wss://" : "ws://") + window.location.host + url);
+		ws.onmessage = function(e) {
+			apply(e.data);
+		};
+		ws.onclose = function() {
+			setTimeout(connect, 1000);
+		};
+	}
+	connect();
+})`
+
+type (
+	// LiveServer pushes new content and attributes for live tags to the connected browsers,
+	// with Server-Sent Events or WebSockets. It must be served at the path that is given
+	// to NewLiveServer, for instance with mux.Handle(path, server).
+	LiveServer struct {
+		path      string
+		websocket bool
+		mu        sync.Mutex
+		counter   int
+		regions   map[string]*liveUpdate // the current state of every region
+		clients   map[chan liveUpdate]bool
+	}
+	// LiveRegion is a handle for a live tag, that can be used for updating the tag in the browsers
+	LiveRegion struct {
+		ID     string
+		server *LiveServer
+	}
+	// liveUpdate is a change to a live tag. A nil attribute value removes the attribute.
+	liveUpdate struct {
+		ID      string             `json:"id"`
+		Content *string            `json:"content,omitempty"`
+		Attrs   map[string]*string `json:"attrs,omitempty"`
+	}
+)
+
+// NewLiveServer creates a new LiveServer, that will be served at the given URL path,
+// for instance "/onthefly/live"
+func NewLiveServer(path string) *LiveServer {
+	return &LiveServer{
+		path:    path,
+		regions: make(map[string]*liveUpdate),
+		clients: make(map[chan liveUpdate]bool),
+	}
+}
+
+// UseWebSocket makes the pages connect with a WebSocket instead of with Server-Sent Events.
+// This must be called before the client script is added to the pages.
+func (s *LiveServer) UseWebSocket() {
+	s.websocket = true
+}
+
+// Live marks the tag as live, and returns a handle that can be used for pushing new
+// content and attributes to the browsers. If the tag has no ID, an ID is generated.
+// The page must include the client script, see Page.UseLive.
+func (tag *Tag) Live(server *LiveServer) *LiveRegion {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	id, found := tag.GetAttribute("id")
+	if !found || id == "" {
+		server.counter++
+		id = "live" + strconv.Itoa(server.counter)
+		tag.AddAttrib("id", id)
+	}
+	tag.AddSingularAttrib("data-live")
+	id = html.UnescapeString(id)
+	if _, found := server.regions[id]; !found {
+		server.regions[id] = &liveUpdate{ID: id}
+	}
+	return &LiveRegion{ID: id, server: server}
+}
+
+// UseLive adds the client script for the given LiveServer to the end of the body.
+// It is only added once.
+func (page *Page) UseLive(server *LiveServer) error {
+	if page.root.FindChildByAttribute("id", liveID) != nil {
+		return nil
+	}
+	body, err := page.GetTag("body")
+	if err != nil {
+		return err
+	}
+	script := body.AddNewTag("script")
+	script.AddAttrib("id", liveID)
+	script.AddAttrib("type", "text/javascript")
+	script.AddContent(liveScript + "(" + quote(server.path) + ", " + strconv.FormatBool(server.websocket) + ");")
+	return nil
+}
+
+// SetContent replaces the contents of the tag with the given HTML, in all connected browsers.
+// Browsers that connect later also get the latest content.
+func (region *LiveRegion) SetContent(content string) {
+	region.server.push(liveUpdate{ID: region.ID, Content: &content})
+}
+
+// SetText replaces the contents of the tag with the given text, which is escaped
+func (region *LiveRegion) SetText(text string) {
+	region.SetContent(html.EscapeString(text))
+}
+
+// SetAttr sets an attribute of the tag, in all connected browsers
+func (region *LiveRegion) SetAttr(name, value string) {
+	region.server.push(liveUpdate{ID: region.ID, Attrs: map[string]*string{name: &value}})
+}
+
+// RemoveAttr removes an attribute from the tag, in all connected browsers
+func (region *LiveRegion) RemoveAttr(name string) {
+	region.server.push(liveUpdate{ID: region.ID, Attrs: map[string]*string{name: nil}})
+}
+
+// push remembers the given update and sends it to all connected browsers.
+// Browsers that can not keep up are disconnected, and get the latest state when they reconnect.
+func (s *LiveServer) push(update liveUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, found := s.regions[update.ID]
+	if !found {
+		state = &liveUpdate{ID: update.ID}
+		s.regions[update.ID] = state
+	}
+	if update.Content != nil {
+		state.Content = update.Content
+	}
+	for name, value := range update.Attrs {
+		if state.Attrs == nil {
+			state.Attrs = make(map[string]*string)
+		}
+		state.Attrs[name] = value
+	}
+	for client := range s.clients {
+		select {
+		case client <- update:
+		default:
+			delete(s.clients, client)
+			close(client)
+		}
+	}
+}
+
+// subscribe registers a new browser, and returns the current state of all regions that have changed
+func (s *LiveServer) subscribe() (chan liveUpdate, []liveUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var snapshot []liveUpdate
+	for _, state := range s.regions {
+		if state.Content != nil || len(state.Attrs) > 0 {
+			update := *state
+			update.Attrs = make(map[string]*string, len(state.Attrs))
+			for name, value := range state.Attrs {
+				update.Attrs[name] = value
+			}
+			snapshot = append(snapshot, update)
+		}
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].ID < snapshot[j].ID
+	})
+	client := make(chan liveUpdate, liveBuffer)
+	s.clients[client] = true
+	return client, snapshot
+}
+
+// unsubscribe removes a browser, unless it has already been removed by push
+func (s *LiveServer) unsubscribe(client chan liveUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients[client] {
+		delete(s.clients, client)
+		close(client)
+	}
+}
+
+// ServeHTTP sends updates to a browser, with a WebSocket if the browser asks for one,
+// or else with Server-Sent Events
+func (s *LiveServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if isWebSocket(req) {
+		s.serveWebSocket(w, req)
+		return
+	}
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.Write([]byte(": connected\n\n"))
+	w.(http.Flusher).Flush()
+
+	client, snapshot := s.subscribe()
+	defer s.unsubscribe(client)
+	for _, update := range snapshot {
+		data, _ := json.Marshal(update)
+		writeEvent(w, "update", string(data))
+	}
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case update, ok := <-client:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(update)
+			writeEvent(w, "update", string(data))
+		}
+	}
+}
+
+// serveWebSocket sends updates to a browser with a WebSocket
+func (s *LiveServer) serveWebSocket(w http.ResponseWriter, req *http.Request) {
+	conn, rw, err := upgradeWebSocket(w, req)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Time{})
+
+	client, snapshot := s.subscribe()
+	defer s.unsubscribe(client)
+
+	var writeMutex sync.Mutex
+	write := func(opcode byte, payload []byte) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		return writeWebSocketFrame(rw.Writer, opcode, payload)
+	}
+
+	// Answer pings, and stop when the browser closes the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			opcode, payload, err := readWebSocketFrame(rw.Reader)
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case websocketPing:
+				write(websocketPong, payload)
+			case websocketClose:
+				write(websocketClose, nil)
+				return
+			}
+		}
+	}()
+
+	for _, update := range snapshot {
+		data, _ := json.Marshal(update)
+		if write(websocketText, data) != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-closed:
+			return
+		case update, ok := <-client:
+			if !ok {
+				write(websocketClose, nil)
+				return
+			}
+			data, _ := json.Marshal(update)
+			if write(websocketText, data) != nil {
+				return
+			}
+		}
+	}
+}
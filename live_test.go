@@ -0,0 +1,131 @@
+package onthefly
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLiveRegionSSE(t *testing.T) {
+	server := NewLiveServer("/live")
+	page := NewHTML5Page("Dashboard")
+	body, _ := page.GetTag("body")
+	counter := body.AddNewTag("span").Live(server)
+	status := body.AddNewTag("p")
+	status.AddAttrib("id", "status")
+	statusRegion := status.Live(server)
+	if counter.ID != "live1" || statusRegion.ID != "status" {
+		t.Errorf("Unexpected IDs %q and %q", counter.ID, statusRegion.ID)
+	}
+	if err := page.UseLive(server); err != nil {
+		t.Fatal(err)
+	}
+	if s := page.String(); !strings.Contains(s, `("/live", false);`) || !strings.Contains(s, "data-live") {
+		t.Errorf("Expected the client script and live tags in the page:\n%s", s)
+	}
+
+	// An update before connecting is sent as the current state
+	counter.SetText("1 < 2")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	if event, data := readEvent(t, events); event != "update" || data != `{"id":"live1","content":"1 \u0026lt; 2"}` {
+		t.Errorf("Expected the current state, got %s %s", event, data)
+	}
+
+	statusRegion.SetAttr("class", "warning")
+	if _, data := readEvent(t, events); data != `{"id":"status","attrs":{"class":"warning"}}` {
+		t.Errorf("Unexpected update %s", data)
+	}
+	statusRegion.RemoveAttr("class")
+	if _, data := readEvent(t, events); data != `{"id":"status","attrs":{"class":null}}` {
+		t.Errorf("Unexpected update %s", data)
+	}
+}
+
+// readServerFrame reads an unmasked frame, as sent by the server
+func readServerFrame(t *testing.T, r *bufio.Reader) (byte, string) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0] & 0x0f, string(payload)
+}
+
+func TestLiveRegionWebSocket(t *testing.T) {
+	server := NewLiveServer("/live")
+	server.UseWebSocket()
+	region := NewTag("div").Live(server)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// A connection from another web site is refused
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "http://evil.example")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a cross-origin connection to be refused, got %d", resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: "+strings.TrimPrefix(ts.URL, "http://")+"\r\n"+
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	// The example key and accept value from RFC 6455
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Unexpected Sec-WebSocket-Accept %q", accept)
+	}
+
+	region.SetContent("<b>42</b>")
+	opcode, payload := readServerFrame(t, r)
+	if opcode != websocketText {
+		t.Fatalf("Expected a text frame, got opcode %d", opcode)
+	}
+	var update liveUpdate
+	if err := json.Unmarshal([]byte(payload), &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.ID != region.ID || update.Content == nil || *update.Content != "<b>42</b>" {
+		t.Errorf("Unexpected update %s", payload)
+	}
+
+	// Send a masked close frame, and expect a close frame back
+	conn.Write([]byte{0x80 | websocketClose, 0x80, 1, 2, 3, 4})
+	if opcode, _ := readServerFrame(t, r); opcode != websocketClose {
+		t.Errorf("Expected a close frame, got opcode %d", opcode)
+	}
+}
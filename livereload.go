@@ -0,0 +1,214 @@
+package onthefly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloaderID is the ID of the script tag that connects a page to a Reloader
+const reloaderID = "onthefly-reload"
+
+// reloadScript connects to the Reloader at the given URL. It reloads the page when the
+// hash of the HTML changes, and only reloads the stylesheets when the hash of the CSS changes.
+// The EventSource reconnects by itself, also after the server has been restarted.
+const reloadScript = `(function(url) {
+	var last = null;
+	var source = new EventSource(url);
+	source.addEventListener("hash", function(e) {
+		var hash = JSON.parse(e.data);
+		if (last && hash.html != last.html) {
+			window.location.reload();
+			return;
+		}
+		if (last && hash.css != last.css) {
+			document.querySelectorAll("link[rel=\"stylesheet\"]").forEach(function(link) {
+				var u = new URL(link.href);
+				u.searchParams.set("reload", Date.now());
+				link.href = u.toString();
+			});
+		}
+		last = hash;
+	});
+	source.addEventListener("reload", function() {
+		window.location.reload();
+	});
+})`
+
+type (
+	// Reloader is a development tool that makes browsers reload pages when the rendered
+	// HTML changes, or refresh only the stylesheets when only the CSS changes.
+	// Browsers are connected with Server-Sent Events, which also makes them reload
+	// after the server has been restarted with changed pages.
+	Reloader struct {
+		path     string
+		interval time.Duration
+		mu       sync.Mutex
+		pages    map[string]func() *Page
+		clients  map[chan struct{}]bool
+	}
+	// pageHash is the hash of the HTML and CSS of a page
+	pageHash struct {
+		HTML string `json:"html"`
+		CSS  string `json:"css"`
+	}
+)
+
+// NewReloader creates a new Reloader. path is the URL path of the Server-Sent Events endpoint,
+// for instance "/onthefly/reload", and it must be handled by the Reloader, for instance with
+// mux.Handle(path, reloader). The pages are checked for changes every 500 milliseconds.
+func NewReloader(path string) *Reloader {
+	return &Reloader{
+		path:     path,
+		interval: 500 * time.Millisecond,
+		pages:    make(map[string]func() *Page),
+		clients:  make(map[chan struct{}]bool),
+	}
+}
+
+// SetInterval sets how often the pages are checked for changes
+func (r *Reloader) SetInterval(interval time.Duration) {
+	r.interval = interval
+}
+
+// hashString returns a short hash of the given string
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Inject adds a script to the end of the body of the page, that connects to the Reloader.
+// htmlurl is the URL of the page, which is used for finding the page when checking for changes.
+// The script is only added once.
+func (r *Reloader) Inject(page *Page, htmlurl string) error {
+	if page.root.FindChildByAttribute("id", reloaderID) != nil {
+		return nil
+	}
+	body, err := page.GetTag("body")
+	if err != nil {
+		return err
+	}
+	script := body.AddNewTag("script")
+	script.AddAttrib("id", reloaderID)
+	script.AddAttrib("type", "text/javascript")
+	script.AddContent(reloadScript + "(" + quote(r.path+"?page="+url.QueryEscape(htmlurl)) + ");")
+	return nil
+}
+
+// render renders the page that is published at the given URL, with the reload script included
+func (r *Reloader) render(htmlurl, cssurl string, render func() *Page) *Page {
+	page := render()
+	if page.root.FindChildByAttribute("href", cssurl) == nil {
+		page.LinkToCSS(cssurl)
+	}
+	r.Inject(page, htmlurl)
+	return page
+}
+
+// Publish is a development version of Page.Publish. The page is rendered again by the given
+// function for every request, and browsers are told to reload when the rendered HTML or CSS changes.
+// render is called concurrently, and should return a new page every time.
+func (r *Reloader) Publish(mux *http.ServeMux, htmlurl, cssurl string, render func() *Page) {
+	r.mu.Lock()
+	r.pages[htmlurl] = func() *Page {
+		return r.render(htmlurl, cssurl, render)
+	}
+	r.mu.Unlock()
+	mux.HandleFunc(htmlurl, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("Content-Type", "text/html")
+		w.Header().Add("Cache-Control", "no-store")
+		fmt.Fprint(w, r.render(htmlurl, cssurl, render).GetHTML())
+	})
+	mux.HandleFunc(cssurl, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("Content-Type", "text/css")
+		w.Header().Add("Cache-Control", "no-store")
+		fmt.Fprint(w, render().GetCSS())
+	})
+}
+
+// Reload tells all connected browsers to reload, for instance after files have changed
+func (r *Reloader) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for client := range r.clients {
+		select {
+		case client <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+}
+
+// hash returns the hash of the page that is published at the given URL
+func (r *Reloader) hash(htmlurl string) (pageHash, bool) {
+	r.mu.Lock()
+	render, found := r.pages[htmlurl]
+	r.mu.Unlock()
+	if !found {
+		return pageHash{}, false
+	}
+	page := render()
+	return pageHash{hashString(page.GetHTML()), hashString(page.GetCSS())}, true
+}
+
+// writeEvent writes a Server-Sent Event and flushes it
+func writeEvent(w http.ResponseWriter, event, data string) {
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, strings.ReplaceAll(data, "\n", "\ndata: "))
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ServeHTTP serves the Server-Sent Events that tell a browser when to reload
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if _, ok := w.(http.Flusher); !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+
+	client := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.clients[client] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, client)
+		r.mu.Unlock()
+	}()
+
+	htmlurl := req.URL.Query().Get("page")
+	last, found := r.hash(htmlurl)
+	if found {
+		data, _ := json.Marshal(last)
+		writeEvent(w, "hash", string(data))
+	} else {
+		// Let the browser know that the connection works
+		w.Write([]byte(": connected\n\n"))
+		w.(http.Flusher).Flush()
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-client:
+			writeEvent(w, "reload", "")
+		case <-ticker.C:
+			if current, found := r.hash(htmlurl); found && current != last {
+				last = current
+				data, _ := json.Marshal(current)
+				writeEvent(w, "hash", string(data))
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package onthefly
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next Server-Sent Event, and returns the event name and the data
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && event != "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestReloader(t *testing.T) {
+	var color, text atomic.Value
+	color.Store("black")
+	text.Store("Hello")
+	render := func() *Page {
+		page := NewHTML5Page("Live")
+		page.AddContent(text.Load().(string))
+		body, _ := page.GetTag("body")
+		body.AddStyle("color", color.Load().(string))
+		return page
+	}
+
+	reloader := NewReloader("/reload")
+	reloader.SetInterval(10 * time.Millisecond)
+	mux := http.NewServeMux()
+	mux.Handle("/reload", reloader)
+	reloader.Publish(mux, "/", "/style.css", render)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, expected := range []string{"EventSource", `"/reload?page=%2F"`, `href="/style.css"`} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("Expected %q in the page:\n%s", expected, html)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/reload?page=%2F")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+	event, first := readEvent(t, events)
+	if event != "hash" {
+		t.Fatalf("Expected a hash event, got %q", event)
+	}
+
+	// Only the CSS changes
+	color.Store("red")
+	_, second := readEvent(t, events)
+	if first[:strings.Index(first, ",")] != second[:strings.Index(second, ",")] {
+		t.Errorf("Expected only the CSS hash to change: %s %s", first, second)
+	}
+	if first == second {
+		t.Error("Expected the CSS hash to change")
+	}
+
+	// The HTML changes
+	text.Store("Hello, World")
+	_, third := readEvent(t, events)
+	if second[:strings.Index(second, ",")] == third[:strings.Index(third, ",")] {
+		t.Errorf("Expected the HTML hash to change: %s %s", second, third)
+	}
+
+	reloader.Reload()
+	if event, _ := readEvent(t, events); event != "reload" {
+		t.Errorf("Expected a reload event, got %q", event)
+	}
+}
+
+func TestDeterministicRendering(t *testing.T) {
+	render := func() string {
+		tag := NewTag("input")
+		for _, name := range []string{"type", "name", "id", "value", "placeholder", "class"} {
+			tag.AddAttrib(name, name)
+		}
+		for _, name := range []string{"color", "margin", "padding", "border", "width"} {
+			tag.AddStyle(name, "0")
+		}
+		return tag.String() + tag.GetCSS()
+	}
+	first := render()
+	for i := 0; i < 20; i++ {
+		if s := render(); s != first {
+			t.Fatalf("Expected the same output every time:\n%s\n%s", first, s)
+		}
+	}
+}
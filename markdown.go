@@ -0,0 +1,913 @@
+package onthefly
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Markdown is converted to a tree of tags, so that the generated tags can be
+// found with GetTag or FindChildByName and styled with AddStyle.
+//
+// The supported subset of CommonMark is: ATX and setext headings, paragraphs,
+// hard line breaks, block quotes, bullet and ordered lists, fenced and indented
+// code blocks, thematic breaks, emphasis, strong emphasis, code spans, links,
+// images and autolinks, as well as GitHub-style tables. Raw HTML and reference
+// links are not supported, and are rendered as text.
+
+// markdownURLs is the policy for link and image destinations. Only relative URLs and the
+// URL schemes that are allowed by DefaultPolicy are linked to.
+var markdownURLs = Policy{URLSchemes: DefaultPolicy().URLSchemes}
+
+// AddMarkdown parses the given Markdown and adds the resulting tags as children of this tag.
+// Links and images with other URL schemes than http, https and mailto, like javascript:,
+// are rendered as text. Untrusted Markdown is otherwise safe to add, since raw HTML is
+// not supported.
+func (tag *Tag) AddMarkdown(src string) {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	addMarkdownBlocks(tag, strings.Split(src, "\n"), false)
+}
+
+// MarkdownPage creates a new HTML5 page with the given title, where the body
+// contains the tags that are generated from the given Markdown
+func MarkdownPage(title, src string) *Page {
+	page := NewHTML5Page(title)
+	body, _ := page.GetTag("body")
+	body.AddMarkdown(src)
+	return page
+}
+
+// isBlank checks if a line only contains whitespace
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// leadingSpaces returns the number of spaces at the start of a line
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// stripSpaces removes up to n spaces from the start of a line
+func stripSpaces(line string, n int) string {
+	return line[min(n, leadingSpaces(line)):]
+}
+
+// atxHeading checks if a line is a heading like "## Heading".
+// Returns the heading level and text.
+func atxHeading(s string) (int, string, bool) {
+	level := 0
+	for level < len(s) && s[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(s) && s[level] != ' ') {
+		return 0, "", false
+	}
+	text := strings.TrimSpace(s[level:])
+	// Remove an optional closing sequence of #'s
+	if trimmed := strings.TrimRight(text, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") {
+		text = strings.TrimSpace(trimmed)
+	}
+	return level, text, true
+}
+
+// isThematicBreak checks if a line is a thematic break, like "***" or "- - -"
+func isThematicBreak(s string) bool {
+	s = strings.TrimSpace(s)
+	if len(s) < 3 || (s[0] != '*' && s[0] != '-' && s[0] != '_') {
+		return false
+	}
+	count := 0
+	for _, r := range s {
+		switch {
+		case r == rune(s[0]):
+			count++
+		case r != ' ':
+			return false
+		}
+	}
+	return count >= 3
+}
+
+// fenceMarker returns the opening code fence of a line, like "```", or an empty string
+func fenceMarker(s string) string {
+	if len(s) < 3 || (s[0] != '`' && s[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(s) && s[n] == s[0] {
+		n++
+	}
+	if n < 3 || (s[0] == '`' && strings.Contains(s[n:], "`")) {
+		return ""
+	}
+	return s[:n]
+}
+
+// setextLevel checks if a line underlines a setext heading.
+// Returns 1 for "===", 2 for "---" and 0 otherwise.
+func setextLevel(line string) int {
+	s := strings.TrimSpace(line)
+	if leadingSpaces(line) > 3 || s == "" {
+		return 0
+	}
+	if strings.Trim(s, "=") == "" {
+		return 1
+	}
+	if strings.Trim(s, "-") == "" {
+		return 2
+	}
+	return 0
+}
+
+// listItem describes the marker at the start of a list item
+type listItem struct {
+	ordered bool
+	marker  byte // '-', '+' or '*' for bullet lists, '.' or ')' for ordered lists
+	start   int  // the number of the first item, for ordered lists
+	offset  int  // where the contents of the list item starts
+	empty   bool // true if the first line of the list item is blank
+}
+
+// parseListMarker checks if a line starts a list item
+func parseListMarker(line string) (listItem, bool) {
+	var item listItem
+	indent := leadingSpaces(line)
+	if indent > 3 {
+		return item, false
+	}
+	s := line[indent:]
+	width := 0
+	switch {
+	case s == "":
+		return item, false
+	case s[0] == '-' || s[0] == '+' || s[0] == '*':
+		item.marker = s[0]
+		width = 1
+	default:
+		digits := 0
+		for digits < len(s) && digits < 9 && s[digits] >= '0' && s[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 || digits >= len(s) || (s[digits] != '.' && s[digits] != ')') {
+			return item, false
+		}
+		item.ordered = true
+		item.marker = s[digits]
+		item.start, _ = strconv.Atoi(s[:digits])
+		width = digits + 1
+	}
+	rest := s[width:]
+	if rest != "" && rest[0] != ' ' {
+		return item, false
+	}
+	spaces := leadingSpaces(rest)
+	item.empty = isBlank(rest)
+	if item.empty || spaces > 4 {
+		spaces = 1
+	}
+	item.offset = indent + width + spaces
+	return item, true
+}
+
+// isTableDelimiter checks if a line is the delimiter row of a table, like "| --- | :-: |"
+func isTableDelimiter(line string) bool {
+	cells := splitTableRow(line)
+	if len(cells) == 0 || !strings.Contains(line, "-") {
+		return false
+	}
+	for _, cell := range cells {
+		if strings.Trim(cell, ":-") != "" || !strings.Contains(cell, "-") {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTableRow splits a table row into trimmed cells. "\|" does not split cells.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+	var (
+		cells []string
+		cell  strings.Builder
+	)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// startsBlock checks if a line would interrupt a paragraph
+func startsBlock(line string) bool {
+	if leadingSpaces(line) > 3 {
+		return false
+	}
+	s := strings.TrimLeft(line, " ")
+	if _, _, ok := atxHeading(s); ok {
+		return true
+	}
+	if isThematicBreak(s) || fenceMarker(s) != "" || strings.HasPrefix(s, ">") {
+		return true
+	}
+	// Only non-empty bullet items and ordered lists starting with 1 interrupt a paragraph
+	if item, ok := parseListMarker(line); ok && !item.empty && (!item.ordered || item.start == 1) {
+		return true
+	}
+	return false
+}
+
+// addCodeBlock adds a <pre><code> block
+func addCodeBlock(parent *Tag, language string, lines []string) {
+	pre := parent.AddNewTag("pre")
+	code := pre.AddNewTag("code")
+	if language != "" {
+		code.AddAttrib("class", "language-"+html.EscapeString(language))
+	}
+	text := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		text += "\n"
+	}
+	code.AddContent(html.EscapeString(text))
+}
+
+// addMarkdownBlocks parses the given lines as Markdown blocks and adds them to the parent tag.
+// If tight is true, paragraphs are added without <p> tags, as in tight lists.
+func addMarkdownBlocks(parent *Tag, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if isBlank(line) {
+			i++
+			continue
+		}
+		indent := leadingSpaces(line)
+
+		// Indented code block
+		if indent >= 4 {
+			var code []string
+			for i < len(lines) && (isBlank(lines[i]) || leadingSpaces(lines[i]) >= 4) {
+				code = append(code, stripSpaces(lines[i], 4))
+				i++
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			addCodeBlock(parent, "", code)
+			continue
+		}
+
+		s := line[indent:]
+
+		// Fenced code block
+		if fence := fenceMarker(s); fence != "" {
+			info := strings.Fields(s[len(fence):])
+			language := ""
+			if len(info) > 0 {
+				language = info[0]
+			}
+			var code []string
+			i++
+			for i < len(lines) {
+				closing := strings.TrimSpace(lines[i])
+				if leadingSpaces(lines[i]) < 4 && strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+					i++
+					break
+				}
+				code = append(code, stripSpaces(lines[i], indent))
+				i++
+			}
+			addCodeBlock(parent, language, code)
+			continue
+		}
+
+		// ATX heading
+		if level, text, ok := atxHeading(s); ok {
+			addInlines(parent.AddNewTag("h"+strconv.Itoa(level)), text)
+			i++
+			continue
+		}
+
+		// Thematic break
+		if isThematicBreak(s) {
+			parent.AddNewTag("hr")
+			i++
+			continue
+		}
+
+		// Block quote
+		if strings.HasPrefix(s, ">") {
+			var quoted []string
+			for i < len(lines) && !isBlank(lines[i]) {
+				q := strings.TrimLeft(lines[i], " ")
+				if leadingSpaces(lines[i]) < 4 && strings.HasPrefix(q, ">") {
+					q = strings.TrimPrefix(q[1:], " ")
+				} else if startsBlock(lines[i]) {
+					break
+				}
+				// Lines without ">" are lazy continuation lines
+				quoted = append(quoted, q)
+				i++
+			}
+			addMarkdownBlocks(parent.AddNewTag("blockquote"), quoted, false)
+			continue
+		}
+
+		// List
+		if first, ok := parseListMarker(line); ok {
+			i = addMarkdownList(parent, lines, i, first)
+			continue
+		}
+
+		// Table
+		if strings.Contains(s, "|") && i+1 < len(lines) && isTableDelimiter(lines[i+1]) {
+			if header := splitTableRow(s); len(header) == len(splitTableRow(lines[i+1])) {
+				i = addMarkdownTable(parent, lines, i)
+				continue
+			}
+		}
+
+		// Paragraph, possibly underlined as a setext heading
+		paragraph := []string{strings.TrimSpace(line)}
+		level := 0
+		for i++; i < len(lines) && !isBlank(lines[i]); i++ {
+			if level = setextLevel(lines[i]); level > 0 {
+				i++
+				break
+			}
+			if startsBlock(lines[i]) {
+				break
+			}
+			paragraph = append(paragraph, strings.TrimLeft(lines[i], " "))
+		}
+		text := strings.Join(paragraph, "\n")
+		switch {
+		case level > 0:
+			addInlines(parent.AddNewTag("h"+strconv.Itoa(level)), text)
+		case tight:
+			if parent.CountChildren() > 0 {
+				parent.AddTextNode("\n")
+			}
+			addInlines(parent, text)
+		default:
+			addInlines(parent.AddNewTag("p"), text)
+		}
+	}
+}
+
+// addMarkdownList adds a <ul> or <ol> list that starts at the given line.
+// Returns the index of the first line after the list.
+func addMarkdownList(parent *Tag, lines []string, i int, first listItem) int {
+	var (
+		items [][]string
+		loose bool
+	)
+	item := first
+	for {
+		// The first line of the item
+		content := []string{""}
+		if !item.empty {
+			content[0] = lines[i][item.offset:]
+		}
+		i++
+		// The rest of the lines that belong to this item
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				content = append(content, "")
+				i++
+				continue
+			}
+			if leadingSpaces(line) >= item.offset {
+				content = append(content, line[item.offset:])
+				i++
+				continue
+			}
+			if _, isItem := parseListMarker(line); isItem {
+				break
+			}
+			if !isBlank(content[len(content)-1]) && !startsBlock(line) {
+				// Lazy continuation line
+				content = append(content, strings.TrimLeft(line, " "))
+				i++
+				continue
+			}
+			break
+		}
+		// Trailing blank lines separate this item from the next one
+		trailing := 0
+		for len(content) > 1 && isBlank(content[len(content)-1]) {
+			content = content[:len(content)-1]
+			trailing++
+		}
+		// Blank lines between the direct children of an item make the list loose
+		for j := 1; j < len(content)-1; j++ {
+			if isBlank(content[j]) && !isBlank(content[j+1]) && leadingSpaces(content[j+1]) == 0 && !isBlank(content[j-1]) {
+				loose = true
+			}
+		}
+		items = append(items, content)
+
+		if i >= len(lines) {
+			break
+		}
+		next, ok := parseListMarker(lines[i])
+		if !ok || next.ordered != first.ordered || next.marker != first.marker || isThematicBreak(lines[i]) {
+			break
+		}
+		if trailing > 0 {
+			loose = true
+		}
+		item = next
+	}
+
+	var list *Tag
+	if first.ordered {
+		list = parent.AddNewTag("ol")
+		if first.start != 1 {
+			list.AddAttrib("start", strconv.Itoa(first.start))
+		}
+	} else {
+		list = parent.AddNewTag("ul")
+	}
+	for _, content := range items {
+		li := list.AddNewTag("li")
+		addMarkdownBlocks(li, content, !loose)
+		if li.CountChildren() == 0 {
+			// Keep empty items from being rendered as <li />
+			li.AddContent(" ")
+		}
+	}
+	return i
+}
+
+// addMarkdownTable adds a <table> that starts at the given line.
+// Returns the index of the first line after the table.
+func addMarkdownTable(parent *Tag, lines []string, i int) int {
+	header := splitTableRow(lines[i])
+	var aligns []string
+	for _, cell := range splitTableRow(lines[i+1]) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	table := parent.AddNewTag("table")
+	addRow := func(section *Tag, cells []string, cellTag string) {
+		tr := section.AddNewTag("tr")
+		for j := range header {
+			cell := tr.AddNewTag(cellTag)
+			if aligns[j] != "" {
+				cell.AddAttrib("style", "text-align: "+aligns[j])
+			}
+			text := ""
+			if j < len(cells) {
+				text = cells[j]
+			}
+			if text == "" {
+				// Keep empty cells from being rendered as <td />
+				cell.AddContent("&nbsp;")
+				continue
+			}
+			addInlines(cell, text)
+		}
+	}
+	addRow(table.AddNewTag("thead"), header, "th")
+	i += 2
+	var tbody *Tag
+	for i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i]) {
+		if tbody == nil {
+			tbody = table.AddNewTag("tbody")
+		}
+		addRow(tbody, splitTableRow(lines[i]), "td")
+		i++
+	}
+	return i
+}
+
+// isPunct checks if a byte is ASCII punctuation, which can be escaped with a backslash
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+// isAlnumAt checks if the byte at the given position is a letter or a digit
+func isAlnumAt(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= utf8.RuneSelf
+}
+
+// runLength returns the number of times the byte at position i is repeated
+func runLength(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+type (
+	// delimiter is a run of "*" or "_" that may open or close emphasis
+	delimiter struct {
+		node     *Tag // the text node with the delimiters that are left
+		char     byte
+		count    int // the number of delimiters that are left
+		length   int // the original number of delimiters
+		canOpen  bool
+		canClose bool
+	}
+	// emphasisKey is used for remembering where the search for an opener stopped
+	emphasisKey struct {
+		char    byte
+		canOpen bool
+		mod3    int
+	}
+)
+
+// isPunctRune checks if a rune is Unicode punctuation or a symbol
+func isPunctRune(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// newDelimiter creates a delimiter for the run of n delimiters at position i, with the
+// left- and right-flanking rules of CommonMark. The start and the end of the text count
+// as whitespace.
+func newDelimiter(node *Tag, s string, i, n int) *delimiter {
+	before, after := ' ', ' '
+	if i > 0 {
+		before, _ = utf8.DecodeLastRuneInString(s[:i])
+	}
+	if i+n < len(s) {
+		after, _ = utf8.DecodeRuneInString(s[i+n:])
+	}
+	left := !unicode.IsSpace(after) && (!isPunctRune(after) || unicode.IsSpace(before) || isPunctRune(before))
+	right := !unicode.IsSpace(before) && (!isPunctRune(before) || unicode.IsSpace(after) || isPunctRune(after))
+	d := &delimiter{node: node, char: s[i], count: n, length: n, canOpen: left, canClose: right}
+	if d.char == '_' {
+		// Intraword underscores do not open or close emphasis
+		d.canOpen = left && (!right || isPunctRune(before))
+		d.canClose = right && (!left || isPunctRune(after))
+	}
+	return d
+}
+
+// processEmphasis matches the delimiters with the CommonMark algorithm, and wraps the
+// nodes in between them in <em> and <strong> tags. Returns the resulting nodes.
+func processEmphasis(nodes []*Tag, delimiters []*delimiter) []*Tag {
+	indexOf := func(node *Tag) int {
+		for i, n := range nodes {
+			if n == node {
+				return i
+			}
+		}
+		return -1
+	}
+	remove := func(node *Tag) {
+		i := indexOf(node)
+		nodes = append(nodes[:i], nodes[i+1:]...)
+	}
+	bottoms := make(map[emphasisKey]int)
+	for c := 0; c < len(delimiters); c++ {
+		closer := delimiters[c]
+		for closer.count > 0 && closer.canClose {
+			key := emphasisKey{closer.char, closer.canOpen, closer.length % 3}
+			o := c - 1
+			for ; o >= bottoms[key]; o-- {
+				opener := delimiters[o]
+				if opener.count == 0 || !opener.canOpen || opener.char != closer.char {
+					continue
+				}
+				// The "multiple of 3" rule, for runs that can both open and close
+				if (opener.canClose || closer.canOpen) && (opener.length+closer.length)%3 == 0 &&
+					(opener.length%3 != 0 || closer.length%3 != 0) {
+					continue
+				}
+				break
+			}
+			if o < bottoms[key] {
+				bottoms[key] = c
+				break
+			}
+			opener := delimiters[o]
+			used, name := 1, "em"
+			if opener.count >= 2 && closer.count >= 2 {
+				used, name = 2, "strong"
+			}
+			opener.count -= used
+			closer.count -= used
+			opener.node.content = strings.Repeat(string(opener.char), opener.count)
+			closer.node.content = strings.Repeat(string(closer.char), closer.count)
+			start, end := indexOf(opener.node), indexOf(closer.node)
+			tag := NewTag(name)
+			tag.setChildren(append([]*Tag(nil), nodes[start+1:end]...))
+			nodes = append(nodes[:start+1], append([]*Tag{tag}, nodes[end:]...)...)
+			// The delimiters in between can no longer be matched
+			for _, d := range delimiters[o+1 : c] {
+				d.count = 0
+			}
+			if opener.count == 0 {
+				remove(opener.node)
+			}
+			if closer.count == 0 {
+				remove(closer.node)
+			}
+		}
+	}
+	return nodes
+}
+
+// findClosingBracket finds the "]" that matches the "[" at position i. Returns -1 if not found.
+func findClosingBracket(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseLinkDestination parses "(destination "title")" at position i.
+// Returns the destination, the title and the position after the closing parenthesis.
+func parseLinkDestination(s string, i int) (string, string, int, bool) {
+	if i >= len(s) || s[i] != '(' {
+		return "", "", 0, false
+	}
+	i++
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	var dest strings.Builder
+	if i < len(s) && s[i] == '<' {
+		end := strings.IndexAny(s[i+1:], ">\n")
+		if end < 0 || s[i+1+end] != '>' {
+			return "", "", 0, false
+		}
+		dest.WriteString(s[i+1 : i+1+end])
+		i += end + 2
+	} else {
+		depth := 0
+	loop:
+		for ; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+				dest.WriteByte(s[i+1])
+				i++
+			case c == '(':
+				depth++
+				dest.WriteByte(c)
+			case c == ')' && depth == 0, c == ' ', c == '\n':
+				break loop
+			case c == ')':
+				depth--
+				dest.WriteByte(c)
+			default:
+				dest.WriteByte(c)
+			}
+		}
+	}
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	title := ""
+	if i < len(s) && (s[i] == '"' || s[i] == '\'' || s[i] == '(') {
+		closer := s[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		// Backslash escapes, like \" in a title in double quotes, are unescaped
+		var sb strings.Builder
+		for i++; i < len(s) && s[i] != closer; i++ {
+			if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+				i++
+			}
+			sb.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return "", "", 0, false
+		}
+		title = sb.String()
+		i++
+		for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+			i++
+		}
+	}
+	if i >= len(s) || s[i] != ')' {
+		return "", "", 0, false
+	}
+	return dest.String(), title, i + 1, true
+}
+
+// plainText returns the text of the text nodes of a tag, recursively
+func plainText(tag *Tag) string {
+	if tag.name == "" {
+		return tag.content
+	}
+	var sb strings.Builder
+	for _, child := range tag.GetChildren() {
+		sb.WriteString(plainText(child))
+	}
+	return sb.String()
+}
+
+// addInlines parses the given text for inline Markdown, like emphasis, code spans and links,
+// and adds text nodes and tags to the parent tag
+func addInlines(parent *Tag, s string) {
+	inline := NewTag("span")
+	delimiters := parseInlines(inline, s)
+	for _, node := range processEmphasis(inline.GetChildren(), delimiters) {
+		node.nextSibling = nil
+		parent.AddChild(node)
+	}
+}
+
+// parseInlines adds text nodes and tags for the given text to the parent tag.
+// The runs of "*" and "_" are added as text nodes, and returned as delimiters.
+func parseInlines(parent *Tag, s string) []*delimiter {
+	var delimiters []*delimiter
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parent.AddTextNode(text.String())
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			// Hard line break
+			flush()
+			parent.AddNewTag("br")
+			i += 2
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			text.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == '\n':
+			if strings.HasSuffix(text.String(), "  ") {
+				// Hard line break
+				trimmed := strings.TrimRight(text.String(), " ")
+				text.Reset()
+				text.WriteString(trimmed)
+				flush()
+				parent.AddNewTag("br")
+			} else {
+				text.WriteByte('\n')
+			}
+			i++
+		case c == '`':
+			n := runLength(s, i)
+			end := -1
+			for j := i + n; j < len(s); {
+				if s[j] == '`' {
+					run := runLength(s, j)
+					if run == n {
+						end = j
+						break
+					}
+					j += run
+					continue
+				}
+				j++
+			}
+			if end < 0 {
+				text.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			flush()
+			parent.AddNewTag("code").AddContent(html.EscapeString(code))
+			i = end + n
+		case c == '!' && i+1 < len(s) && s[i+1] == '[', c == '[':
+			image := c == '!'
+			open := i
+			if image {
+				open++
+			}
+			closing := findClosingBracket(s, open)
+			if closing < 0 {
+				text.WriteString(s[i : open+1])
+				i = open + 1
+				continue
+			}
+			dest, title, end, ok := parseLinkDestination(s, closing+1)
+			if !ok {
+				text.WriteString(s[i : open+1])
+				i = open + 1
+				continue
+			}
+			flush()
+			label := s[open+1 : closing]
+			if !markdownURLs.allowsURL(dest) {
+				// Links and images with schemes like javascript: are rendered as text
+				if image {
+					alt := NewTag("span")
+					addInlines(alt, label)
+					parent.AddTextNode(plainText(alt))
+				} else {
+					addInlines(parent, label)
+				}
+			} else if image {
+				img := parent.AddNewTag("img")
+				img.AddAttrib("src", html.EscapeString(dest))
+				alt := NewTag("span")
+				addInlines(alt, label)
+				img.AddAttrib("alt", plainText(alt))
+				if title != "" {
+					img.AddAttrib("title", html.EscapeString(title))
+				}
+			} else {
+				a := parent.AddNewTag("a")
+				a.AddAttrib("href", html.EscapeString(dest))
+				if title != "" {
+					a.AddAttrib("title", html.EscapeString(title))
+				}
+				addInlines(a, label)
+			}
+			i = end
+		case c == '<':
+			// Autolinks, like <https://example.com> or <user@example.com>
+			end := strings.IndexAny(s[i+1:], "> \n<")
+			if end > 0 && s[i+1+end] == '>' {
+				address := s[i+1 : i+1+end]
+				href := ""
+				if scheme := strings.Index(address, ":"); scheme > 1 && isAlnumAt(address, 0) && markdownURLs.allowsURL(address) {
+					href = address
+				} else if strings.Contains(address, "@") && !strings.ContainsAny(address, "\\:") {
+					href = "mailto:" + address
+				}
+				if href != "" {
+					flush()
+					a := parent.AddNewTag("a")
+					a.AddAttrib("href", html.EscapeString(href))
+					a.AddTextNode(html.EscapeString(address))
+					i += end + 2
+					continue
+				}
+			}
+			text.WriteString("&lt;")
+			i++
+		case c == '&':
+			// Keep entity references, like &copy; or &#169;
+			if end := strings.IndexByte(s[i:], ';'); end > 1 && end < 32 && strings.Trim(s[i+1:i+end], "#abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") == "" {
+				text.WriteString(s[i : i+end+1])
+				i += end + 1
+				continue
+			}
+			text.WriteString("&amp;")
+			i++
+		case c == '*' || c == '_':
+			n := runLength(s, i)
+			flush()
+			delimiters = append(delimiters, newDelimiter(parent.AddTextNode(s[i:i+n]), s, i, n))
+			i += n
+		case c == '"':
+			text.WriteString("&#34;")
+			i++
+		case c == '>':
+			text.WriteString("&gt;")
+			i++
+		case c == '\'':
+			text.WriteString("&#39;")
+			i++
+		default:
+			text.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return delimiters
+}
@@ -0,0 +1,151 @@
+package onthefly
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownBlocks(t *testing.T) {
+	src := `# Title
+
+Some *emphasis*, **strong** and ` + "`code <b>`" + ` in a paragraph
+that spans two lines.
+
+Setext heading
+--------------
+
+- one
+- two
+  - nested
+- three
+
+3. three
+4. four
+
+> quoted
+> text
+
+` + "```go" + `
+fmt.Println("<hi>")
+` + "```" + `
+
+---
+
+| Name | Price |
+|:-----|------:|
+| Tea  | 2     |
+| Cake |       |
+`
+	body := NewTag("body")
+	body.AddMarkdown(src)
+
+	if h1 := body.FindChildByName("h1"); h1 == nil || plainText(h1) != "Title" {
+		t.Errorf("Expected a h1 tag with the title")
+	}
+	if h2 := body.FindChildByName("h2"); h2 == nil || plainText(h2) != "Setext heading" {
+		t.Errorf("Expected a h2 tag from the setext heading")
+	}
+	if em := body.FindChildByName("em"); em == nil || plainText(em) != "emphasis" {
+		t.Errorf("Expected an em tag")
+	}
+	if ul := body.FindChildByName("ul"); ul == nil || ul.CountChildren() != 3 {
+		t.Errorf("Expected an ul tag with three items")
+	}
+	if ol := body.FindChildByName("ol"); ol == nil || ol.CountChildren() != 2 {
+		t.Errorf("Expected an ol tag with two items")
+	} else if start, _ := ol.GetAttribute("start"); start != "3" {
+		t.Errorf("Expected the ordered list to start at 3, got %q", start)
+	}
+	if body.FindChildByName("blockquote") == nil || body.FindChildByName("hr") == nil {
+		t.Errorf("Expected a blockquote and a hr tag")
+	}
+	if code := body.FindChildByAttribute("class", "language-go"); code == nil || code.GetContent() != "fmt.Println(&#34;&lt;hi&gt;&#34;)\n" {
+		t.Errorf("Expected an escaped go code block")
+	}
+	if table := body.FindChildByName("table"); table == nil || table.FindChildByName("tbody").CountChildren() != 2 {
+		t.Errorf("Expected a table with two rows")
+	}
+
+	s := body.String()
+	for _, expected := range []string{
+		"<p>Some <em>emphasis</em>, <strong>strong</strong> and <code>code &lt;b&gt;</code> in a paragraph\nthat spans two lines.</p>",
+		"<li>two<ul>",
+		"<pre><code class=\"language-go\">fmt.Println(",
+		"<th style=\"text-align: left\">Name</th>",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, s)
+		}
+	}
+	// The tags can be styled like any other tag
+	body.FindChildByName("h1").AddStyle("color", "red")
+	if !strings.Contains(getCSSRecursively(body), "h1 {\n  color: red;\n}") {
+		t.Error("Expected the h1 tag to be styleable")
+	}
+}
+
+func TestMarkdownInlines(t *testing.T) {
+	tests := []struct {
+		src, expected string
+	}{
+		{"[a *link*](http://example.com \"Title\")", "<a href=\"http://example.com\" title=\"Title\">a <em>link</em></a>"},
+		{"[l](http://x \"t\\\"\")", "<a href=\"http://x\" title=\"t&#34;\">l</a>"},
+		{"[l](/a 't\\'s')", "<a href=\"/a\" title=\"t&#39;s\">l</a>"},
+		{"[l](/a (t\\)))", "<a href=\"/a\" title=\"t)\">l</a>"},
+		{"![An image](/img.png)", "<img src=\"/img.png\" alt=\"An image\" />"},
+		{"<https://example.com>", "<a href=\"https://example.com\">https://example.com</a>"},
+		{"<javascript:alert(1)>", "&lt;javascript:alert(1)&gt;"},
+		{"***both***", "<em><strong>both</strong></em>"},
+		{"*a **b***", "<em>a <strong>b</strong></em>"},
+		{"**a *b***", "<strong>a <em>b</em></strong>"},
+		{"*foo**bar**baz*", "<em>foo<strong>bar</strong>baz</em>"},
+		{"*foo**bar*", "<em>foo**bar</em>"},
+		{"**foo*", "*<em>foo</em>"},
+		{"_foo_bar_", "<em>foo_bar</em>"},
+		{"****four****", "<strong><strong>four</strong></strong>"},
+		{"snake_case_name", "snake_case_name"},
+		{"\\*not emphasis\\*", "*not emphasis*"},
+		{"a <b>tag</b> & &copy;", "a &lt;b&gt;tag&lt;/b&gt; &amp; &copy;"},
+		{"line  \nbreak", "line<br />break"},
+		{"unclosed *star", "unclosed *star"},
+		{"[a *link*](javascript:alert(1))", "a <em>link</em>"},
+		{"[a link](\tJaVaScRiPt:alert(1))", "a link"},
+		{"![An <image>](data:text/html,x)", "An &lt;image&gt;"},
+		{"[relative](docs/a:b.html)", "<a href=\"docs/a:b.html\">relative</a>"},
+	}
+	for _, test := range tests {
+		p := NewTag("p")
+		addInlines(p, test.src)
+		var sb strings.Builder
+		for _, child := range p.GetChildren() {
+			sb.WriteString(getXMLRecursively(child, false, 0))
+		}
+		if got := sb.String(); !strings.Contains(got, test.expected) && !attributesMatch(got, test.expected) {
+			t.Errorf("Markdown %q: expected %q, got %q", test.src, test.expected, got)
+		}
+		if s := strings.ToLower(p.String()); strings.Contains(s, "=\"javascript:") || strings.Contains(s, "=\"data:") {
+			t.Errorf("Markdown %q: expected no unsafe URLs, got %q", test.src, s)
+		}
+	}
+}
+
+// attributesMatch checks if two rendered tags are equal, regardless of attribute order
+func attributesMatch(a, b string) bool {
+	fields := func(s string) map[string]int {
+		m := make(map[string]int)
+		for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == '<' || r == '>' }) {
+			m[f]++
+		}
+		return m
+	}
+	fa, fb := fields(a), fields(b)
+	if len(fa) != len(fb) {
+		return false
+	}
+	for k, v := range fa {
+		if fb[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package onthefly
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// threeLoadersID is the ID of the script tag that includes the glTF and OBJ loaders
+const threeLoadersID = "onthefly-three-loaders"
+
+// modelTypes are the content types of 3D model files, by extension
+var modelTypes = map[string]string{
+	".gltf": "model/gltf+json",
+	".glb":  "model/gltf-binary",
+	".bin":  "application/octet-stream",
+	".obj":  "model/obj",
+	".mtl":  "model/mtl",
+}
+
+// Model represents a 3D model that is loaded from a glTF, GLB or OBJ file.
+// The model is a THREE.Group that the loaded object is added to, so it can be
+// positioned and added to the scene right away, before it has been loaded.
+type Model Element
+
+// addThreeScript adds the given script tag to the body, before the script that sets up the
+// Three.JS scene, so that it runs after Three.JS itself, but before the scene is created.
+// The script is only added once.
+func (page *Page) addThreeScript(id string, script *Tag) error {
+	if page.root.FindChildByAttribute("id", id) != nil {
+		return nil
+	}
+	body, err := page.GetTag("body")
+	if err != nil {
+		return err
+	}
+	script.AddAttrib("id", id)
+	script.AddAttrib("type", "text/javascript")
+	children := body.GetChildren()
+	i := 0
+	for i < len(children) && !(children[i].name == "script" && strings.Contains(children[i].content, "new THREE.Scene")) {
+		i++
+	}
+	body.setChildren(append(children[:i:i], append([]*Tag{script}, children[i:]...)...))
+	return nil
+}
+
+// UseThreeLoaders makes sure that the page includes the embedded glTF and OBJ loaders,
+// which are needed by LoadModel. They are placed after Three.JS and before the scene.
+// This is done automatically by AddScene when the scene loads models.
+func (page *Page) UseThreeLoaders() error {
+	script := NewTag("script")
+	script.AddContent(modelsJS)
+	return page.addThreeScript(threeLoadersID, script)
+}
+
+// UseThreeLoadersFrom makes sure that the page links to the glTF and OBJ loaders at the given URL.
+// ServeThreeLoaders can be used for serving the embedded loaders.
+func (page *Page) UseThreeLoadersFrom(loadersURL string) error {
+	script := NewTag("script")
+	script.AddAttrib("src", loadersURL)
+	script.AddContent(" ")
+	return page.addThreeScript(threeLoadersID, script)
+}
+
+// ServeThreeLoaders serves the embedded glTF and OBJ loaders at the given URL
+func ServeThreeLoaders(mux *http.ServeMux, loadersURL string) {
+	mux.HandleFunc(loadersURL, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Add("Content-Type", "text/javascript")
+		w.Write([]byte(modelsJS))
+	})
+}
+
+// ServeModels serves the model files in the given file system, below the given URL path prefix,
+// like "/models/". glTF files can refer to buffers and textures with relative paths.
+func ServeModels(mux *http.ServeMux, prefix string, fsys fs.FS) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(fsys)))
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/") {
+			http.NotFound(w, req) // no directory listings
+			return
+		}
+		if contentType, found := modelTypes[strings.ToLower(path.Ext(req.URL.Path))]; found {
+			w.Header().Set("Content-Type", contentType)
+		}
+		files.ServeHTTP(w, req)
+	})
+}
+
+// usesThreeLoaders checks if the given JavaScript code uses the glTF or OBJ loader
+func usesThreeLoaders(code string) bool {
+	return strings.Contains(code, "THREE.GLTFLoader") || strings.Contains(code, "THREE.OBJLoader")
+}
+
+// LoadModel creates a model that is loaded from the given URL. The format is given by the
+// extension: .gltf or .glb for glTF, or .obj for OBJ. The page must include the loaders,
+// see UseThreeLoaders.
+// The loaders are compact, and support a subset of the formats. For glTF: triangle meshes,
+// the node hierarchy, PBR materials with textures and node animations. Morph targets,
+// cameras, lights and optional extensions are ignored, while files with skins, sparse
+// accessors or required extensions fail to load, with an error in the browser console.
+// For OBJ: vertices, normals, texture coordinates and faces, with one mesh per object
+// or group. Material libraries (.mtl) are not loaded.
+func (s *Scene) LoadModel(url string) (*Model, error) {
+	var loader string
+	filename := url
+	if i := strings.IndexAny(filename, "?#"); i >= 0 {
+		filename = filename[:i]
+	}
+	switch ext := strings.ToLower(path.Ext(filename)); ext {
+	case ".gltf", ".glb":
+		loader = "GLTFLoader"
+	case ".obj":
+		loader = "OBJLoader"
+	default:
+		return nil, fmt.Errorf("unsupported model format: %q", ext)
+	}
+	id := s.nextID(modelPrefix)
+	// The load event lets OnLoad change the object before it is added to the model
+	onLoad := js.Func{Params: []string{"loaded"}, Body: []js.Stmt{
+		js.Var("object", js.Raw("loaded.scene || loaded")),
+		js.ExprStmt(js.Method(js.Ident(id), "dispatchEvent", js.Object{
+			"type":       js.String("load"),
+			"object":     js.Ident("object"),
+			"animations": js.Raw("loaded.animations || []"),
+		})),
+		js.ExprStmt(js.Method(js.Ident(id), "add", js.Ident("object"))),
+	}}
+	onError := js.Func{Params: []string{"err"}, Body: []js.Stmt{
+		js.ExprStmt(js.Call(js.Ident("console.error"), js.String("could not load "+url+":"), js.Ident("err"))),
+	}}
+	code := newThree(id, "Group")
+	code += js.ExprStmt(js.Method(js.New(threeClass(loader)), "load", js.String(url), onLoad, js.Ident("undefined"), onError)).String()
+	m := &Model{ID: id, JS: code}
+	s.register((*Element)(m))
+	if !s.global {
+		s.mu.Lock()
+		s.models = append(s.models, m)
+		s.mu.Unlock()
+	}
+	return m, nil
+}
+
+// Models returns the models that have been created by the scene
+func (s *Scene) Models() []*Model {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Model(nil), s.models...)
+}
+
+// LoadModel creates a model that is loaded from the given URL, see Scene.LoadModel
+func LoadModel(url string) (*Model, error) {
+	return defaultScene.LoadModel(url)
+}
+
+// Element returns the model as an Element
+func (m *Model) Element() *Element {
+	return (*Element)(m)
+}
+
+// OnLoad adds statements that are run when the model has been loaded. The loaded object is
+// in the "object" variable, before it is added to the model, and the animations of a glTF
+// model are in the "animations" variable.
+func (m *Model) OnLoad(stmts ...js.Stmt) {
+	listener := js.Func{Params: []string{"e"}, Body: append([]js.Stmt{
+		js.Var("object", js.Ident("e.object")),
+		js.Var("animations", js.Ident("e.animations")),
+	}, stmts...)}
+	m.JS += call(m.ID, "addEventListener", js.String("load"), listener)
+}
+
+// FitTo scales the loaded object so that its largest side has the given size,
+// and centers it in the model
+func (m *Model) FitTo(size float64) {
+	m.OnLoad(
+		js.Var("box", js.Method(js.New(threeClass("Box3")), "setFromObject", js.Ident("object"))),
+		js.Var("largest", js.Raw("Math.max.apply(null, box.getSize(new THREE.Vector3()).toArray())")),
+		js.If(js.Raw("largest > 0"), js.ExprStmt(js.Method(js.Ident("object.scale"), "multiplyScalar", js.Raw(js.Value(size).String()+" / largest")))),
+		js.ExprStmt(js.Method(js.Ident("object.position"), "sub", js.Raw("box.setFromObject(object).getCenter(new THREE.Vector3())"))),
+	)
+}
+
+// SetPosition sets the position of the model
+func (m *Model) SetPosition(x, y, z float64) {
+	(*Element)(m).SetPosition(x, y, z)
+}
+
+// SetRotation sets the rotation of the model
+func (m *Model) SetRotation(x, y, z float64) {
+	(*Element)(m).SetRotation(x, y, z)
+}
+
+// SetScale sets the scale of the model
+func (m *Model) SetScale(x, y, z float64) {
+	(*Element)(m).SetScale(x, y, z)
+}
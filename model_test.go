@@ -0,0 +1,170 @@
+package onthefly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadModel(t *testing.T) {
+	scene := NewScene()
+	model, err := scene.LoadModel("/models/duck.glb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.SetPosition(0, 1, 0)
+	model.FitTo(2)
+	scene.Add(model)
+	s := scene.String()
+	for _, expected := range []string{
+		"var model0 = new THREE.Group();",
+		`new THREE.GLTFLoader().load("/models/duck.glb", function(loaded) { var object = loaded.scene || loaded;`,
+		`model0.addEventListener("load", function(e) { var object = e.object;`,
+		"object.scale.multiplyScalar(2 / largest);",
+		"model0.position.set(0, 1, 0);",
+		"scene.add(model0);",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, s)
+		}
+	}
+	if len(scene.Models()) != 1 {
+		t.Error("Expected the model to be registered")
+	}
+	if obj, err := LoadModel("teapot.OBJ?v=2"); err != nil || !strings.Contains(obj.JS, "new THREE.OBJLoader().load(") {
+		t.Errorf("Expected an OBJ loader, got %v", err)
+	}
+	if _, err := scene.LoadModel("/models/duck.fbx"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+
+	// The loaders are placed after Three.JS and before the scene
+	page, three := NewThreeJS()
+	page.AddScene(scene)
+	page.UseThreeLoaders()
+	html := page.String()
+	loaders := strings.Index(html, `id="onthefly-three-loaders"`)
+	if strings.Count(html, `id="onthefly-three-loaders"`) != 1 || loaders < strings.Index(html, "three.min.js") ||
+		loaders > strings.Index(html, three.content) {
+		t.Errorf("Expected the loaders once, between Three.JS and the scene:\n%s", html)
+	}
+}
+
+func TestServeModels(t *testing.T) {
+	mux := http.NewServeMux()
+	ServeModels(mux, "/models", fstest.MapFS{
+		"duck.glb":        {Data: []byte("glTF")},
+		"cube/cube.gltf":  {Data: []byte("{}")},
+		"cube/cube.bin":   {Data: []byte{0}},
+		"cube/readme.txt": {Data: []byte("hello")},
+	})
+	for path, expected := range map[string]string{
+		"/models/duck.glb":       "model/gltf-binary",
+		"/models/cube/cube.gltf": "model/gltf+json",
+		"/models/cube/cube.bin":  "application/octet-stream",
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != expected {
+			t.Errorf("%s: expected %s, got %d %s", path, expected, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/models/cube/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected no directory listing, got %d", rec.Code)
+	}
+}
+
+// loadersTestJS loads the models in testdata with the embedded loaders, and prints what was loaded
+const loadersTestJS = `var fs = require("fs");
+var describe = function(object) {
+	var d = {name: object.name, type: object.type, y: object.position.y, children: object.children.map(describe)};
+	if (object.geometry) {
+		d.vertices = object.geometry.attributes.position.count;
+		d.indices = object.geometry.index ? object.geometry.index.count : 0;
+		d.uvs = !!object.geometry.attributes.uv;
+		d.color = object.material.color.getHexString();
+	}
+	return d;
+};
+var gltf = function(text) {
+	return new Promise(function(resolve) {
+		new THREE.GLTFLoader().parse(text, "", function(loaded) { resolve(describe(loaded.scene)); },
+			function(err) { resolve({error: err.message}); });
+	});
+};
+var triangle = fs.readFileSync("testdata/triangle.gltf", "utf8");
+var skinned = JSON.parse(triangle);
+skinned.skins = [{joints: [1]}];
+Promise.all([gltf(triangle), gltf(JSON.stringify(skinned))]).then(function(results) {
+	results.push(describe(new THREE.OBJLoader().parse(fs.readFileSync("testdata/shapes.obj", "utf8"))));
+	console.log(JSON.stringify(results));
+});`
+
+// runNode runs the given script with node, after Three.JS and the given scripts,
+// and returns what it prints. The test is skipped if node is not installed.
+func runNode(t *testing.T, script string, scripts ...string) []byte {
+	t.Helper()
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is needed for running the JavaScript code")
+	}
+	three, err := filepath.Abs("three.min.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := "global.THREE = require(" + strconv.Quote(three) + ");\n" + strings.Join(append(scripts, script), "\n")
+	filename := filepath.Join(t.TempDir(), "test.js")
+	if err := os.WriteFile(filename, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(node, filename).Output()
+	if err != nil {
+		t.Fatalf("Could not run the JavaScript code: %v", err)
+	}
+	return out
+}
+
+func TestModelLoadersJS(t *testing.T) {
+	out := runNode(t, loadersTestJS, modelsJS)
+	type object struct {
+		Name     string
+		Type     string
+		Y        float64
+		Children []object
+		Vertices int
+		Indices  int
+		UVs      bool
+		Color    string
+		Error    string
+	}
+	var results []object
+	if err := json.Unmarshal(out, &results); err != nil || len(results) != 3 {
+		t.Fatalf("Unexpected output: %s", out)
+	}
+	gltf, skinned, obj := results[0], results[1], results[2]
+	if len(gltf.Children) != 1 || gltf.Children[0].Name != "root" || gltf.Children[0].Y != 2 || len(gltf.Children[0].Children) != 1 {
+		t.Fatalf("Expected the node hierarchy of the glTF file: %s", out)
+	}
+	if mesh := gltf.Children[0].Children[0]; mesh.Type != "Mesh" || mesh.Vertices != 3 || mesh.Indices != 3 || mesh.Color != "ff0000" {
+		t.Errorf("Expected a red triangle: %+v", mesh)
+	}
+	if skinned.Error != "unsupported glTF feature: skins" {
+		t.Errorf("Expected models with skins to be rejected: %+v", skinned)
+	}
+	if len(obj.Children) != 2 {
+		t.Fatalf("Expected two objects in the OBJ file: %s", out)
+	}
+	if triangle, quad := obj.Children[0], obj.Children[1]; triangle.Name != "triangle" || triangle.Vertices != 3 || triangle.UVs ||
+		quad.Name != "quad" || quad.Vertices != 6 || !quad.UVs {
+		t.Errorf("Expected a triangle and a quad with texture coordinates: %s", out)
+	}
+}
@@ -0,0 +1,417 @@
+// Compact glTF/GLB and OBJ loaders for onthefly, with the same API as the loaders
+// in the Three.JS examples. Supported: meshes with triangles, the node hierarchy,
+// PBR materials with textures, and node animations. Not supported: skins, morph
+// targets, sparse accessors, cameras, lights and extensions. Files that need skins,
+// sparse accessors or required extensions are rejected, instead of being drawn wrong.
+(function(THREE) {
+	"use strict";
+
+	function baseURL(url) {
+		var i = url.lastIndexOf("/");
+		return i < 0 ? "" : url.slice(0, i + 1);
+	}
+
+	function resolveURL(uri, path) {
+		if (/^(data:|blob:|[a-z][a-z0-9+.-]*:\/\/|\/)/i.test(uri)) {
+			return uri;
+		}
+		return path + uri;
+	}
+
+	function fetchData(url, binary) {
+		return fetch(url).then(function(r) {
+			if (!r.ok) {
+				throw new Error(url + ": " + r.status + " " + r.statusText);
+			}
+			return binary ? r.arrayBuffer() : r.text();
+		});
+	}
+
+	// glTF
+
+	var GLB_MAGIC = 0x46546c67, GLB_JSON = 0x4e4f534a, GLB_BIN = 0x004e4942;
+	var COMPONENTS = {SCALAR: 1, VEC2: 2, VEC3: 3, VEC4: 4, MAT2: 4, MAT3: 9, MAT4: 16};
+	var ARRAYS = {5120: Int8Array, 5121: Uint8Array, 5122: Int16Array, 5123: Uint16Array, 5125: Uint32Array, 5126: Float32Array};
+
+	// unsupported returns an error if the glTF file uses features that would not be drawn right
+	function unsupported(json) {
+		if (json.extensionsRequired && json.extensionsRequired.length) {
+			return new Error("unsupported glTF extensions: " + json.extensionsRequired.join(", "));
+		}
+		if (json.skins && json.skins.length) {
+			return new Error("unsupported glTF feature: skins");
+		}
+		if ((json.accessors || []).some(function(accessor) { return accessor.sparse; })) {
+			return new Error("unsupported glTF feature: sparse accessors");
+		}
+		return null;
+	}
+
+	function GLTFLoader() {}
+
+	GLTFLoader.prototype.load = function(url, onLoad, onProgress, onError) {
+		var self = this;
+		fetchData(url, true).then(function(data) {
+			self.parse(data, baseURL(url), onLoad, onError);
+		}).catch(onError || console.error);
+	};
+
+	// parse parses glTF JSON (as a string or an ArrayBuffer) or GLB (as an ArrayBuffer)
+	GLTFLoader.prototype.parse = function(data, path, onLoad, onError) {
+		onError = onError || console.error;
+		var json, bin = null;
+		try {
+			if (typeof data != "string" && new DataView(data).getUint32(0, true) == GLB_MAGIC) {
+				var view = new DataView(data), offset = 12;
+				while (offset < view.byteLength) {
+					var length = view.getUint32(offset, true), type = view.getUint32(offset + 4, true);
+					var chunk = data.slice(offset + 8, offset + 8 + length);
+					if (type == GLB_JSON) {
+						json = JSON.parse(new TextDecoder().decode(chunk));
+					} else if (type == GLB_BIN) {
+						bin = chunk;
+					}
+					offset += 8 + length;
+				}
+			} else {
+				json = JSON.parse(typeof data == "string" ? data : new TextDecoder().decode(data));
+			}
+		} catch (err) {
+			onError(err);
+			return;
+		}
+		var problem = unsupported(json);
+		if (problem) {
+			onError(problem);
+			return;
+		}
+		Promise.all((json.buffers || []).map(function(buffer) {
+			if (buffer.uri === undefined) {
+				return Promise.resolve(bin);
+			}
+			return fetchData(resolveURL(buffer.uri, path), true);
+		})).then(function(buffers) {
+			onLoad(new GLTFParser(json, buffers, path).parse());
+		}).catch(onError);
+	};
+
+	function GLTFParser(json, buffers, path) {
+		this.json = json;
+		this.buffers = buffers;
+		this.path = path;
+		this.cache = {};
+	}
+
+	GLTFParser.prototype.cached = function(kind, index, create) {
+		var key = kind + index;
+		if (!(key in this.cache)) {
+			this.cache[key] = create.call(this, this.json[kind][index]);
+		}
+		return this.cache[key];
+	};
+
+	// accessor returns the data of an accessor as a packed typed array, and the item size
+	GLTFParser.prototype.accessor = function(index) {
+		return this.cached("accessors", index, function(accessor) {
+			var size = COMPONENTS[accessor.type], ArrayType = ARRAYS[accessor.componentType];
+			var count = accessor.count * size;
+			if (accessor.bufferView === undefined) {
+				return {array: new ArrayType(count), size: size, normalized: !!accessor.normalized};
+			}
+			var view = this.json.bufferViews[accessor.bufferView];
+			var buffer = this.buffers[view.buffer];
+			var offset = (view.byteOffset || 0) + (accessor.byteOffset || 0);
+			var itemBytes = size * ArrayType.BYTES_PER_ELEMENT;
+			var array;
+			if (view.byteStride && view.byteStride != itemBytes) {
+				// Interleaved data is copied into a packed array
+				array = new ArrayType(count);
+				for (var i = 0; i < accessor.count; i++) {
+					var item = new ArrayType(buffer.slice(offset + i * view.byteStride, offset + i * view.byteStride + itemBytes));
+					array.set(item, i * size);
+				}
+			} else {
+				array = new ArrayType(buffer.slice(offset, offset + count * ArrayType.BYTES_PER_ELEMENT));
+			}
+			return {array: array, size: size, normalized: !!accessor.normalized};
+		});
+	};
+
+	GLTFParser.prototype.texture = function(index) {
+		return this.cached("textures", index, function(def) {
+			var image = this.json.images[def.source], url;
+			if (image.bufferView !== undefined) {
+				var view = this.json.bufferViews[image.bufferView];
+				var start = view.byteOffset || 0;
+				var blob = new Blob([this.buffers[view.buffer].slice(start, start + view.byteLength)], {type: image.mimeType});
+				url = URL.createObjectURL(blob);
+			} else {
+				url = resolveURL(image.uri, this.path);
+			}
+			var texture = new THREE.TextureLoader().load(url);
+			var sampler = def.sampler !== undefined ? this.json.samplers[def.sampler] : {};
+			var wrap = {33071: THREE.ClampToEdgeWrapping, 33648: THREE.MirroredRepeatWrapping, 10497: THREE.RepeatWrapping};
+			texture.wrapS = wrap[sampler.wrapS] || THREE.RepeatWrapping;
+			texture.wrapT = wrap[sampler.wrapT] || THREE.RepeatWrapping;
+			texture.flipY = false;
+			return texture;
+		});
+	};
+
+	GLTFParser.prototype.material = function(index) {
+		return this.cached("materials", index, function(def) {
+			var pbr = def.pbrMetallicRoughness || {};
+			var material = new THREE.MeshStandardMaterial({
+				metalness: pbr.metallicFactor !== undefined ? pbr.metallicFactor : 1,
+				roughness: pbr.roughnessFactor !== undefined ? pbr.roughnessFactor : 1,
+				side: def.doubleSided ? THREE.DoubleSide : THREE.FrontSide
+			});
+			material.name = def.name || "";
+			if (pbr.baseColorFactor) {
+				material.color.fromArray(pbr.baseColorFactor);
+				material.opacity = pbr.baseColorFactor[3];
+			}
+			if (pbr.baseColorTexture) {
+				material.map = this.texture(pbr.baseColorTexture.index);
+				material.map.colorSpace = THREE.SRGBColorSpace;
+			}
+			if (pbr.metallicRoughnessTexture) {
+				material.metalnessMap = material.roughnessMap = this.texture(pbr.metallicRoughnessTexture.index);
+			}
+			if (def.normalTexture) {
+				material.normalMap = this.texture(def.normalTexture.index);
+			}
+			if (def.emissiveFactor) {
+				material.emissive.fromArray(def.emissiveFactor);
+			}
+			if (def.emissiveTexture) {
+				material.emissiveMap = this.texture(def.emissiveTexture.index);
+				material.emissiveMap.colorSpace = THREE.SRGBColorSpace;
+			}
+			if (def.alphaMode == "BLEND") {
+				material.transparent = true;
+				material.depthWrite = false;
+			} else if (def.alphaMode == "MASK") {
+				material.alphaTest = def.alphaCutoff !== undefined ? def.alphaCutoff : 0.5;
+			}
+			return material;
+		});
+	};
+
+	GLTFParser.prototype.mesh = function(index) {
+		var def = this.json.meshes[index], self = this;
+		var meshes = def.primitives.filter(function(primitive) {
+			return primitive.mode === undefined || primitive.mode == 4;
+		}).map(function(primitive) {
+			var geometry = new THREE.BufferGeometry();
+			var names = {POSITION: "position", NORMAL: "normal", TEXCOORD_0: "uv", COLOR_0: "color"};
+			Object.keys(names).forEach(function(name) {
+				if (primitive.attributes[name] !== undefined) {
+					var a = self.accessor(primitive.attributes[name]);
+					geometry.setAttribute(names[name], new THREE.BufferAttribute(a.array, a.size, a.normalized));
+				}
+			});
+			if (primitive.indices !== undefined) {
+				geometry.setIndex(new THREE.BufferAttribute(self.accessor(primitive.indices).array, 1));
+			}
+			if (!geometry.getAttribute("normal")) {
+				geometry.computeVertexNormals();
+			}
+			var material = primitive.material !== undefined ? self.material(primitive.material) : new THREE.MeshStandardMaterial();
+			if (geometry.getAttribute("color")) {
+				material = material.clone();
+				material.vertexColors = true;
+			}
+			return new THREE.Mesh(geometry, material);
+		});
+		if (meshes.length == 1) {
+			return meshes[0];
+		}
+		var group = new THREE.Group();
+		meshes.forEach(function(mesh) {
+			group.add(mesh);
+		});
+		return group;
+	};
+
+	GLTFParser.prototype.node = function(index) {
+		return this.cached("nodes", index, function(def) {
+			var object = def.mesh !== undefined ? this.mesh(def.mesh) : new THREE.Object3D();
+			object.name = def.name || "";
+			if (def.matrix) {
+				object.matrix.fromArray(def.matrix);
+				object.matrix.decompose(object.position, object.quaternion, object.scale);
+			} else {
+				if (def.translation) {
+					object.position.fromArray(def.translation);
+				}
+				if (def.rotation) {
+					object.quaternion.fromArray(def.rotation);
+				}
+				if (def.scale) {
+					object.scale.fromArray(def.scale);
+				}
+			}
+			var self = this;
+			(def.children || []).forEach(function(child) {
+				object.add(self.node(child));
+			});
+			return object;
+		});
+	};
+
+	GLTFParser.prototype.animation = function(def, index) {
+		var self = this, tracks = [];
+		def.channels.forEach(function(channel) {
+			if (channel.target.node === undefined) {
+				return;
+			}
+			var sampler = def.samplers[channel.sampler];
+			var times = self.accessor(sampler.input).array;
+			var output = self.accessor(sampler.output);
+			var values = output.array;
+			if (sampler.interpolation == "CUBICSPLINE") {
+				// Keep the values, and skip the tangents
+				var size = output.size, linear = new values.constructor(values.length / 3);
+				for (var i = 0; i < linear.length / size; i++) {
+					linear.set(values.subarray((3 * i + 1) * size, (3 * i + 2) * size), i * size);
+				}
+				values = linear;
+			}
+			var Track, property;
+			switch (channel.target.path) {
+			case "translation":
+				Track = THREE.VectorKeyframeTrack;
+				property = ".position";
+				break;
+			case "rotation":
+				Track = THREE.QuaternionKeyframeTrack;
+				property = ".quaternion";
+				break;
+			case "scale":
+				Track = THREE.VectorKeyframeTrack;
+				property = ".scale";
+				break;
+			default:
+				return; // morph target weights are not supported
+			}
+			var interpolation = sampler.interpolation == "STEP" ? THREE.InterpolateDiscrete : THREE.InterpolateLinear;
+			var name = self.node(channel.target.node).uuid + property;
+			tracks.push(new Track(name, Array.from(times), Array.from(values), interpolation));
+		});
+		return new THREE.AnimationClip(def.name || "animation" + index, -1, tracks);
+	};
+
+	GLTFParser.prototype.parse = function() {
+		var self = this, json = this.json;
+		var scenes = (json.scenes || [{nodes: (json.nodes || []).map(function(_, i) { return i; })}]).map(function(def) {
+			var group = new THREE.Group();
+			group.name = def.name || "";
+			(def.nodes || []).forEach(function(node) {
+				group.add(self.node(node));
+			});
+			return group;
+		});
+		return {
+			scene: scenes[json.scene || 0],
+			scenes: scenes,
+			animations: (json.animations || []).map(function(def, i) {
+				return self.animation(def, i);
+			}),
+			asset: json.asset
+		};
+	};
+
+	// OBJ
+
+	function OBJLoader() {}
+
+	OBJLoader.prototype.load = function(url, onLoad, onProgress, onError) {
+		var self = this;
+		fetchData(url, false).then(function(text) {
+			onLoad(self.parse(text));
+		}).catch(onError || console.error);
+	};
+
+	// parse parses the text of an OBJ file, and returns a group with a mesh for each object
+	OBJLoader.prototype.parse = function(text) {
+		var positions = [], normals = [], uvs = [];
+		var group = new THREE.Group(), current = null;
+
+		function start(name) {
+			current = {name: name, positions: [], normals: [], uvs: []};
+		}
+
+		function finish() {
+			if (!current || current.positions.length == 0) {
+				return;
+			}
+			var geometry = new THREE.BufferGeometry();
+			geometry.setAttribute("position", new THREE.Float32BufferAttribute(current.positions, 3));
+			if (current.normals.length == current.positions.length) {
+				geometry.setAttribute("normal", new THREE.Float32BufferAttribute(current.normals, 3));
+			} else {
+				geometry.computeVertexNormals();
+			}
+			if (current.uvs.length / 2 == current.positions.length / 3) {
+				geometry.setAttribute("uv", new THREE.Float32BufferAttribute(current.uvs, 2));
+			}
+			var mesh = new THREE.Mesh(geometry, new THREE.MeshPhongMaterial());
+			mesh.name = current.name;
+			group.add(mesh);
+		}
+
+		function index(value, length) {
+			var i = parseInt(value, 10);
+			return i < 0 ? length + i : i - 1;
+		}
+
+		function vertex(ref) {
+			var parts = ref.split("/");
+			var p = index(parts[0], positions.length / 3);
+			current.positions.push(positions[3 * p], positions[3 * p + 1], positions[3 * p + 2]);
+			if (parts[1]) {
+				var t = index(parts[1], uvs.length / 2);
+				current.uvs.push(uvs[2 * t], uvs[2 * t + 1]);
+			}
+			if (parts[2]) {
+				var n = index(parts[2], normals.length / 3);
+				current.normals.push(normals[3 * n], normals[3 * n + 1], normals[3 * n + 2]);
+			}
+		}
+
+		start("");
+		text.split(/\r?\n/).forEach(function(line) {
+			var fields = line.trim().split(/\s+/);
+			switch (fields[0]) {
+			case "v":
+				positions.push(parseFloat(fields[1]), parseFloat(fields[2]), parseFloat(fields[3]));
+				break;
+			case "vn":
+				normals.push(parseFloat(fields[1]), parseFloat(fields[2]), parseFloat(fields[3]));
+				break;
+			case "vt":
+				uvs.push(parseFloat(fields[1]), parseFloat(fields[2]));
+				break;
+			case "f":
+				// Polygons are split into triangles that share the first vertex
+				for (var i = 2; i + 1 < fields.length; i++) {
+					vertex(fields[1]);
+					vertex(fields[i]);
+					vertex(fields[i + 1]);
+				}
+				break;
+			case "o":
+			case "g":
+				finish();
+				start(fields.slice(1).join(" "));
+				break;
+			}
+		});
+		finish();
+		return group;
+	};
+
+	THREE.GLTFLoader = THREE.GLTFLoader || GLTFLoader;
+	THREE.OBJLoader = THREE.OBJLoader || OBJLoader;
+})(THREE);
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -30,6 +31,7 @@ type Tag struct {
 // Page represents an XML/HTML/SVG page with a root tag and title
 type Page struct {
 	root  *Tag
+	dom   DOMBackend
 	title string
 }
 
@@ -87,7 +89,8 @@ func (tag *Tag) AddSingularAttrib(attrName string) {
 	tag.attrs[attrName] = noAttribute
 }
 
-// GetCSS renders CSS for a given tag
+// GetCSS renders CSS for a given tag. The properties are sorted by name,
+// so that the output is the same every time.
 func (tag *Tag) GetCSS() (ret string) {
 	if len(tag.style) == 0 {
 		return
@@ -105,19 +108,29 @@ func (tag *Tag) GetCSS() (ret string) {
 
 	ret += " {\n"
 
-	// Attributes may appear in any order
-	for key, value := range tag.style {
-		ret += "  " + key + ": " + value + ";\n"
+	for _, key := range sortedKeys(tag.style) {
+		ret += "  " + key + ": " + tag.style[key] + ";\n"
 	}
 
 	return ret + "}\n\n"
 }
 
+// sortedKeys returns the keys of the given map, in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // GetAttrString returns a string that represents all the attribute keys and
-// values of a tag. This can be used when generating XML, SVG or HTML.
+// values of a tag, sorted by key. This can be used when generating XML, SVG or HTML.
 func (tag *Tag) GetAttrString() string {
 	ret := ""
-	for key, value := range tag.attrs {
+	for _, key := range sortedKeys(tag.attrs) {
+		value := tag.attrs[key]
 		if value == noAttribute {
 			ret += key + " "
 		} else {
@@ -148,6 +161,10 @@ func (tag *Tag) getFlatXML(indent bool, level int) string {
 	if indent {
 		newLine = "\n"
 	}
+	// For text nodes
+	if tag.name == "" {
+		return tag.content
+	}
 	// For the root tag
 	if (len(tag.name) > 0) && (tag.name[0] == '<') {
 		return tag.name + newLine + tag.content + tag.xmlContent + tag.lastContent
@@ -167,7 +184,9 @@ func (tag *Tag) getFlatXML(indent bool, level int) string {
 		ret += " />"
 	} else {
 		if len(tag.xmlContent) > 0 {
-			if tag.xmlContent[0] != ' ' {
+			if tag.hasInlineChildren() {
+				ret += ">" + tag.xmlContent + "</" + tag.name + ">"
+			} else if tag.xmlContent[0] != ' ' {
 				ret += ">" + newLine + spacing + tag.xmlContent + newLine + spacing + "</" + tag.name + ">"
 			} else {
 				ret += ">" + newLine + tag.xmlContent + spacing + "</" + tag.name + ">"
@@ -179,6 +198,20 @@ func (tag *Tag) getFlatXML(indent bool, level int) string {
 	return ret
 }
 
+// hasInlineChildren checks if the children of a tag must be rendered without
+// added whitespace, because the tag contains text nodes or preformatted text.
+func (tag *Tag) hasInlineChildren() bool {
+	if tag.name == "pre" {
+		return true
+	}
+	for child := tag.firstChild; child != nil; child = child.nextSibling {
+		if child.name == "" {
+			return true
+		}
+	}
+	return false
+}
+
 // GetChildren returns all children for a given tag.
 // Returns a slice of pointers to tags.
 func (tag *Tag) GetChildren() []*Tag {
@@ -210,6 +243,38 @@ func (tag *Tag) AddContent(content string) {
 	tag.content += content
 }
 
+// AddTextNode adds a text node as a child of a tag.
+// Unlike AddContent, this lets text be placed in between child tags, for example:
+// <p>text<em>child</em>more text</p>
+// The text is used as it is, and is not escaped. Returns the text node.
+func (tag *Tag) AddTextNode(text string) *Tag {
+	textNode := newTextNode(text)
+	tag.AddChild(textNode)
+	return textNode
+}
+
+// newTextNode creates a text node with the given (already escaped) text
+func newTextNode(text string) *Tag {
+	textNode := NewTag("")
+	textNode.content = text
+	return textNode
+}
+
+// setChildren replaces the children of a tag with the given tags
+func (tag *Tag) setChildren(children []*Tag) {
+	tag.firstChild = nil
+	var last *Tag
+	for _, child := range children {
+		child.nextSibling = nil
+		if last == nil {
+			tag.firstChild = child
+		} else {
+			last.nextSibling = child
+		}
+		last = child
+	}
+}
+
 // AppendContent appends content to the end of the existing content of a tag
 func (tag *Tag) AppendContent(content string) {
 	tag.lastContent += content
@@ -317,9 +382,13 @@ func getXMLRecursively(cursor *Tag, indent bool, level int) string {
 
 	level++
 
+	// Text nodes and their siblings are rendered without indentation,
+	// since whitespace between them is significant
+	childIndent := indent && !cursor.hasInlineChildren()
+
 	child := cursor.firstChild
 	for child != nil {
-		xmlContent = getXMLRecursively(child, indent, level)
+		xmlContent = getXMLRecursively(child, childIndent, level)
 		if len(xmlContent) > 0 {
 			content += xmlContent
 		}
@@ -365,3 +365,22 @@ func BenchmarkFindChildByName(b *testing.B) {
 		parent.FindChildByName("target")
 	}
 }
+
+func TestSortedOutput(t *testing.T) {
+	tag := NewTag("div")
+	tag.AddAttrib("id", "box")
+	tag.AddAttrib("class", "wide")
+	tag.AddAttrib("data-x", "1")
+	tag.AddSingularAttrib("hidden")
+	tag.AddStyle("width", "10em")
+	tag.AddStyle("color", "red")
+	tag.AddStyle("margin", "0")
+	for i := 0; i < 10; i++ {
+		if s := tag.GetAttrString(); s != `class="wide" data-x="1" hidden id="box"` {
+			t.Fatalf("Expected the attributes to be sorted, got %s", s)
+		}
+		if s := tag.GetCSS(); s != "#box {\n  color: red;\n  margin: 0;\n  width: 10em;\n}\n\n" {
+			t.Fatalf("Expected the properties to be sorted, got %q", s)
+		}
+	}
+}
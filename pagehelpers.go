@@ -12,6 +12,18 @@ var angularJS string
 //go:embed three.min.js
 var threeJS string
 
+//go:embed jquery.min.js
+var jqueryJS string
+
+//go:embed fragments.js
+var fragmentsJS string
+
+//go:embed models.js
+var modelsJS string
+
+//go:embed controls.js
+var controlsJS string
+
 type (
 	// SimpleWebHandle is a function signature for handling requests
 	SimpleWebHandle (func(string) string)
@@ -0,0 +1,175 @@
+package onthefly
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// pickPathPrefix is the prefix of the generated paths for pick endpoints
+const pickPathPrefix = "/onthefly/pick/"
+
+// pickCounter is used for generating unique paths for pick endpoints
+var pickCounter atomic.Uint64
+
+// pickingJS defines the function that sets up picking for a camera and a canvas. Clicks and
+// the object under the pointer are found with a raycaster, and "click" and "hover" events are
+// dispatched to the first object that is hit, or to the nearest parent that listens for them,
+// as marked in userData.picking.
+// It returns a function that updates the hovered object, and is called at every frame, so
+// that moving objects are also picked.
+const pickingJS = `var enablePicking = function(scene, camera, dom) {
+  var raycaster = new THREE.Raycaster(), pointer = new THREE.Vector2();
+  var inside = false, down = null, hovered = null;
+  var setPointer = function(e) {
+    var rect = dom.getBoundingClientRect();
+    pointer.set((e.clientX - rect.left) / rect.width * 2 - 1, -(e.clientY - rect.top) / rect.height * 2 + 1);
+  };
+  var hit = function() {
+    raycaster.setFromCamera(pointer, camera);
+    return raycaster.intersectObject(scene, true)[0];
+  };
+  var listener = function(hit, type) {
+    for (var o = hit && hit.object; o; o = o.parent) {
+      if (o.userData.picking && o.userData.picking[type]) { return o; }
+    }
+    return null;
+  };
+  dom.addEventListener("pointermove", function(e) { setPointer(e); inside = true; });
+  dom.addEventListener("pointerleave", function() { inside = false; });
+  dom.addEventListener("pointerdown", function(e) { down = [e.clientX, e.clientY]; });
+  dom.addEventListener("click", function(e) {
+    if (down && Math.abs(e.clientX - down[0]) + Math.abs(e.clientY - down[1]) > 5) { return; }
+    setPointer(e);
+    var h = hit(), object = listener(h, "click");
+    if (object) { object.dispatchEvent({type: "click", point: h.point}); }
+  });
+  return function() {
+    var h = inside ? hit() : null, object = listener(h, "hover");
+    dom.style.cursor = listener(h, "click") ? "pointer" : "";
+    if (object !== hovered) {
+      if (hovered) { hovered.dispatchEvent({type: "hover", hovered: false, point: null}); }
+      hovered = object;
+      if (object) { object.dispatchEvent({type: "hover", hovered: true, point: h.point}); }
+    }
+  };
+};`
+
+type (
+	// PickEvent is what is posted to a PickEndpoint when a mesh is clicked or hovered
+	PickEvent struct {
+		ID      string   `json:"id"`      // the variable name of the mesh, like "m0"
+		Type    string   `json:"type"`    // "click" or "hover"
+		Hovered bool     `json:"hovered"` // for hover events, if the pointer entered or left the mesh
+		Point   *Vector3 `json:"point"`   // where the mesh was hit, or nil when the pointer left it
+	}
+	// PickEndpoint is a URL that clicks and hovers on meshes can be posted to,
+	// so that a 3D scene can be driven from the server. See Mesh.OnClick.
+	PickEndpoint struct {
+		Path string // the generated URL path
+	}
+)
+
+// NewPickEndpoint registers a handler on the given mux, under a generated path.
+// The given function is called with the event whenever a PickEvent is posted to it.
+// Only JSON is accepted, so that other sites can not post events with plain forms.
+func NewPickEndpoint(mux *http.ServeMux, f func(*http.Request, PickEvent)) *PickEndpoint {
+	path := pickPathPrefix + strconv.FormatUint(pickCounter.Add(1), 10)
+	mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !isJSON(req) {
+			http.Error(w, "the pick event must be JSON", http.StatusUnsupportedMediaType)
+			return
+		}
+		var event PickEvent
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 4096)).Decode(&event); err != nil || event.ID == "" {
+			http.Error(w, "invalid pick event", http.StatusBadRequest)
+			return
+		}
+		f(req, event)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return &PickEndpoint{Path: path}
+}
+
+// Post returns a statement that posts the picked mesh to the endpoint,
+// for use with Mesh.OnClick and Mesh.OnHover
+func (p *PickEndpoint) Post() js.Stmt {
+	body := js.Object{
+		"id":      js.Ident("id"),
+		"type":    js.Ident("type"),
+		"hovered": js.Ident("hovered"),
+		"point":   js.Raw("point && {x: point.x, y: point.y, z: point.z}"),
+	}
+	return js.ExprStmt(js.Call(js.Ident("fetch"), js.String(p.Path), js.Object{
+		"method":  js.String("POST"),
+		"headers": js.Object{"Content-Type": js.String("application/json")},
+		"body":    js.Call(js.Ident("JSON.stringify"), body),
+	}))
+}
+
+// addPickListener makes the mesh run the given statements when the given pick event is dispatched
+func (m *Mesh) addPickListener(event string, stmts []js.Stmt) {
+	listener := js.Func{Params: []string{"e"}, Body: append([]js.Stmt{
+		js.Var("object", js.Ident("e.target")),
+		js.Var("id", js.String(m.ID)),
+		js.Var("type", js.Ident("e.type")),
+		js.Var("hovered", js.Raw("!!e.hovered")),
+		js.Var("point", js.Ident("e.point")),
+	}, stmts...)}
+	picking := js.Ident(m.ID + ".userData.picking")
+	m.JS += js.Assign(picking, js.Call(js.Ident("Object.assign"), js.Raw(picking.String()+" || {}"), js.Object{event: js.Value(true)})).String()
+	m.JS += call(m.ID, "addEventListener", js.String(event), listener)
+	m.pickable = true
+}
+
+// OnClick adds statements that are run when the mesh is clicked. The mesh is in the "object"
+// variable, its ID in "id" and the point that was clicked, as a THREE.Vector3, in "point".
+// Clicks at the end of a drag, like when rotating with orbit controls, are ignored.
+// PickEndpoint.Post can be used for sending the click to the server.
+// The render function must do the picking, which is done automatically for a Scene,
+// see RenderFunc.AddPicking.
+func (m *Mesh) OnClick(stmts ...js.Stmt) {
+	m.addPickListener("click", stmts)
+}
+
+// OnHover adds statements that are run when the pointer enters or leaves the mesh.
+// The "hovered" variable is true when it enters, and the other variables are the same
+// as for OnClick, except that "point" is null when the pointer leaves.
+func (m *Mesh) OnHover(stmts ...js.Stmt) {
+	m.addPickListener("hover", stmts)
+}
+
+// hasPicking checks if the element or any of its children have click or hover handlers
+func (e *Element) hasPicking() bool {
+	if e.pickable {
+		return true
+	}
+	for _, child := range e.children {
+		if child.hasPicking() {
+			return true
+		}
+	}
+	return false
+}
+
+// AddPicking makes the render function find the meshes that are clicked or hovered, with
+// the camera and the canvas of the renderer that it renders with. This is done automatically
+// for the render functions of a Scene that has meshes with OnClick or OnHover.
+func (r *RenderFunc) AddPicking() {
+	picker := r.rendererID + "Picker"
+	dom := js.Ident(r.rendererID + ".domElement")
+	if r.markAdded(picker) {
+		return
+	}
+	setup := js.Var(picker, js.Call(js.Ident("enablePicking"), js.Ident(r.sceneID), js.Ident(r.cameraID), dom))
+	r.addSetup(pickingJS, string(setup))
+	r.AddUpdate(js.ExprStmt(js.Call(js.Ident(picker))))
+}
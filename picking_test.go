@@ -0,0 +1,103 @@
+package onthefly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+func TestPickEndpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	var events []PickEvent
+	p := NewPickEndpoint(mux, func(_ *http.Request, event PickEvent) {
+		events = append(events, event)
+	})
+	for _, c := range []struct {
+		method, contentType, body string
+		status                    int
+	}{
+		{"POST", "application/json", `{"id":"m0","type":"click","hovered":false,"point":{"x":1,"y":2,"z":0.5}}`, http.StatusNoContent},
+		{"POST", "application/json; charset=utf-8", `{"id":"m1","type":"hover","hovered":false,"point":null}`, http.StatusNoContent},
+		{"POST", "application/json", `{"type":"click"}`, http.StatusBadRequest},
+		{"POST", "application/json", `not json`, http.StatusBadRequest},
+		{"POST", "text/plain", `{"id":"m0","type":"click"}`, http.StatusUnsupportedMediaType},
+		{"POST", "", `{"id":"m0","type":"click"}`, http.StatusUnsupportedMediaType},
+		{"GET", "", "", http.StatusMethodNotAllowed},
+	} {
+		req := httptest.NewRequest(c.method, p.Path, strings.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("Expected %d for %s %q with %q, got %d", c.status, c.method, c.body, c.contentType, rec.Code)
+		}
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if e := events[0]; e.ID != "m0" || e.Type != "click" || e.Point == nil || *e.Point != (Vector3{1, 2, 0.5}) {
+		t.Errorf("Unexpected click event: %+v", e)
+	}
+	if e := events[1]; e.ID != "m1" || e.Hovered || e.Point != nil {
+		t.Errorf("Unexpected hover event: %+v", e)
+	}
+	expected := `fetch("` + p.Path + `", {body: JSON.stringify({hovered: hovered, id: id, ` +
+		`point: point && {x: point.x, y: point.y, z: point.z}, type: type}), headers: {"Content-Type": "application/json"}, method: "POST"});`
+	if s := p.Post().String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+}
+
+func TestScenePicking(t *testing.T) {
+	scene := NewScene()
+	camera := scene.NewPerspectiveCamera(75, 1.5, 0.1, 1000)
+	renderer := scene.NewWebGLRenderer(true)
+	scene.AddRenderFunction(scene.NewRenderFunction(renderer, camera), true)
+	if strings.Contains(scene.String(), "enablePicking") {
+		t.Error("Expected no picking without click or hover handlers")
+	}
+	// Meshes in groups can also be picked
+	cube := scene.NewMesh(scene.NewBoxGeometry(1, 1, 1), scene.NewNormalMaterial())
+	group := scene.NewGroup()
+	group.Add(cube)
+	scene.Add(group)
+	cube.OnClick(js.Stmt("alert(id);"))
+	cube.OnHover(js.Stmt("object.scale.setScalar(hovered ? 1.2 : 1);"))
+	s := scene.String()
+	for _, expected := range []string{
+		`m0.userData.picking = Object.assign(m0.userData.picking || {}, {click: true});m0.addEventListener("click", function(e) { ` +
+			`var object = e.target;var id = "m0";var type = e.type;var hovered = !!e.hovered;var point = e.point;alert(id); });`,
+		`m0.userData.picking = Object.assign(m0.userData.picking || {}, {hover: true});m0.addEventListener("hover", function(e) {`,
+		"var renderer0Picker = enablePicking(scene, cam0, renderer0.domElement);var render = function() { requestAnimationFrame(render);" +
+			"var delta = renderClock.getDelta();renderer0Picker();renderer0.render(scene, cam0); };",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %s in:\n%s", expected, s)
+		}
+	}
+	if strings.Count(s, "var enablePicking") != 1 {
+		t.Error("Expected the picking function to be declared once")
+	}
+}
+
+func TestRenderFuncAddPicking(t *testing.T) {
+	_, three := NewThreeJS()
+	cube := NewMesh(NewBoxGeometry(1, 1, 1), NewNormalMaterial())
+	cube.OnClick(js.Stmt("console.log(id);"))
+	three.AddToScene(cube)
+	if !strings.Contains(three.String(), `addEventListener("click"`) {
+		t.Error("Expected the click handler to be added to the script")
+	}
+	r := NewRenderFunction()
+	r.AddPicking()
+	r.AddPicking()
+	s := r.String()
+	if strings.Count(s, "var rendererPicker = enablePicking(scene, camera, renderer.domElement);") != 1 || strings.Count(s, "rendererPicker();") != 1 {
+		t.Errorf("Expected picking to be added once: %s", s)
+	}
+}
@@ -0,0 +1,301 @@
+package onthefly
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/xyproto/tinysvg"
+)
+
+// GeometryData is vertex data that is generated in Go, for instance by Terrain,
+// ParametricSurface or Extrude. It can be turned into geometry with NewGeometryFromData.
+type GeometryData struct {
+	Positions []float32 // x, y and z for each vertex
+	Normals   []float32 // x, y and z for each vertex
+	UVs       []float32 // u and v for each vertex
+	Indices   []uint32  // three vertex indices for each triangle
+}
+
+// VertexCount returns the number of vertices
+func (d *GeometryData) VertexCount() int {
+	return len(d.Positions) / 3
+}
+
+// TriangleCount returns the number of triangles
+func (d *GeometryData) TriangleCount() int {
+	if len(d.Indices) == 0 {
+		return d.VertexCount() / 3
+	}
+	return len(d.Indices) / 3
+}
+
+// addVertex adds a vertex with the given position, normal and uv, and returns its index
+func (d *GeometryData) addVertex(p, n Vector3, u, v float64) uint32 {
+	d.Positions = append(d.Positions, float32(p.X), float32(p.Y), float32(p.Z))
+	d.Normals = append(d.Normals, float32(n.X), float32(n.Y), float32(n.Z))
+	d.UVs = append(d.UVs, float32(u), float32(v))
+	return uint32(d.VertexCount() - 1)
+}
+
+// position returns the position of the vertex with the given index
+func (d *GeometryData) position(i uint32) Vector3 {
+	return Vector3{float64(d.Positions[3*i]), float64(d.Positions[3*i+1]), float64(d.Positions[3*i+2])}
+}
+
+// ComputeNormals sets the normals to the average of the normals of the triangles that
+// share each vertex, which gives smooth shading. Triangles are counter-clockwise when
+// seen from the front.
+func (d *GeometryData) ComputeNormals() {
+	sums := make([]Vector3, d.VertexCount())
+	for t := 0; t+2 < len(d.Indices); t += 3 {
+		a, b, c := d.Indices[t], d.Indices[t+1], d.Indices[t+2]
+		// The cross product is weighted by the area of the triangle
+		n := cross(sub(d.position(b), d.position(a)), sub(d.position(c), d.position(a)))
+		for _, i := range []uint32{a, b, c} {
+			sums[i] = Vector3{sums[i].X + n.X, sums[i].Y + n.Y, sums[i].Z + n.Z}
+		}
+	}
+	d.Normals = make([]float32, 0, len(d.Positions))
+	for _, n := range sums {
+		n = normalize(n)
+		d.Normals = append(d.Normals, float32(n.X), float32(n.Y), float32(n.Z))
+	}
+}
+
+// check returns an error if any of the positions are not finite numbers
+func (d *GeometryData) check() error {
+	for _, x := range d.Positions {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return errors.New("the generated positions must be finite numbers")
+		}
+	}
+	return nil
+}
+
+func sub(a, b Vector3) Vector3 {
+	return Vector3{a.X - b.X, a.Y - b.Y, a.Z - b.Z}
+}
+
+func cross(a, b Vector3) Vector3 {
+	return Vector3{a.Y*b.Z - a.Z*b.Y, a.Z*b.X - a.X*b.Z, a.X*b.Y - a.Y*b.X}
+}
+
+func normalize(v Vector3) Vector3 {
+	length := math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+	if length == 0 {
+		return v
+	}
+	return Vector3{v.X / length, v.Y / length, v.Z / length}
+}
+
+// grid generates a surface of (nu+1)*(nv+1) vertices, with the given position for
+// vertex (i, j). The front of the triangles faces the direction of dv × du.
+func grid(nu, nv int, vertex func(i, j int) Vector3) (*GeometryData, error) {
+	if nu < 1 || nv < 1 {
+		return nil, fmt.Errorf("there must be at least one segment in each direction, got %d and %d", nu, nv)
+	}
+	d := &GeometryData{}
+	for j := 0; j <= nv; j++ {
+		for i := 0; i <= nu; i++ {
+			d.addVertex(vertex(i, j), Vector3{}, float64(i)/float64(nu), 1-float64(j)/float64(nv))
+		}
+	}
+	for j := 0; j < nv; j++ {
+		for i := 0; i < nu; i++ {
+			a := uint32(j*(nu+1) + i)
+			b := a + 1
+			c := a + uint32(nu+1)
+			d.Indices = append(d.Indices, a, c, b, b, c, c+1)
+		}
+	}
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	d.ComputeNormals()
+	return d, nil
+}
+
+// Terrain generates a heightmap terrain that is centered at the origin, in the XZ plane.
+// height returns the height (Y) at the given X and Z.
+func Terrain(width, depth float64, segmentsX, segmentsZ int, height func(x, z float64) float64) (*GeometryData, error) {
+	return grid(segmentsX, segmentsZ, func(i, j int) Vector3 {
+		x := width * (float64(i)/float64(segmentsX) - 0.5)
+		z := depth * (float64(j)/float64(segmentsZ) - 0.5)
+		return Vector3{x, height(x, z), z}
+	})
+}
+
+// TerrainFromImage generates a heightmap terrain with one vertex for each pixel,
+// where white is maxHeight and black is 0. The top of the image is at the far end (-Z).
+func TerrainFromImage(img *image.Gray, width, depth, maxHeight float64) (*GeometryData, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() < 2 || bounds.Dy() < 2 {
+		return nil, errors.New("the heightmap must be at least 2x2 pixels")
+	}
+	segmentsX, segmentsZ := bounds.Dx()-1, bounds.Dy()-1
+	return grid(segmentsX, segmentsZ, func(i, j int) Vector3 {
+		y := float64(img.GrayAt(bounds.Min.X+i, bounds.Min.Y+j).Y) / 255 * maxHeight
+		return Vector3{width * (float64(i)/float64(segmentsX) - 0.5), y, depth * (float64(j)/float64(segmentsZ) - 0.5)}
+	})
+}
+
+// ParametricSurface generates a surface from a function that returns a point for
+// u and v from 0 to 1. The front of the surface faces the direction of df/dv × df/du.
+func ParametricSurface(f func(u, v float64) Vector3, uSegments, vSegments int) (*GeometryData, error) {
+	return grid(uSegments, vSegments, func(i, j int) Vector3 {
+		return f(float64(i)/float64(uSegments), float64(j)/float64(vSegments))
+	})
+}
+
+// polygonArea returns the signed area of a polygon, which is positive if it is counter-clockwise
+func polygonArea(points []*tinysvg.Pos) float64 {
+	area := 0.0
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	return area / 2
+}
+
+// insideTriangle checks if p is inside the counter-clockwise triangle a, b, c
+func insideTriangle(p, a, b, c *tinysvg.Pos) bool {
+	side := func(p, a, b *tinysvg.Pos) float64 {
+		return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+	}
+	return side(p, a, b) >= 0 && side(p, b, c) >= 0 && side(p, c, a) >= 0
+}
+
+// triangulate splits a simple, counter-clockwise polygon into triangles by ear clipping,
+// and returns the indices of the points
+func triangulate(points []*tinysvg.Pos) ([]int, error) {
+	remaining := make([]int, len(points))
+	for i := range remaining {
+		remaining[i] = i
+	}
+	var triangles []int
+	for len(remaining) > 3 {
+		found := false
+		for k := range remaining {
+			ia, ib, ic := remaining[(k+len(remaining)-1)%len(remaining)], remaining[k], remaining[(k+1)%len(remaining)]
+			a, b, c := points[ia], points[ib], points[ic]
+			turn := (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+			if turn == 0 {
+				// Drop a point on a straight line
+				remaining = append(remaining[:k], remaining[k+1:]...)
+				found = true
+				break
+			}
+			if turn < 0 {
+				continue // a reflex corner
+			}
+			ear := true
+			for _, other := range remaining {
+				if other != ia && other != ib && other != ic && insideTriangle(points[other], a, b, c) {
+					ear = false
+					break
+				}
+			}
+			if ear {
+				triangles = append(triangles, ia, ib, ic)
+				remaining = append(remaining[:k], remaining[k+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("the polygon can not be triangulated, it must not intersect itself")
+		}
+	}
+	return append(triangles, remaining...), nil
+}
+
+// Extrude generates a 3D shape by extruding a 2D polygon, like the points of a tinysvg
+// polygon, from Z=0 to Z=depth. The Y axis is flipped, since it points down in SVG.
+// The polygon must not intersect itself.
+func Extrude(points []*tinysvg.Pos, depth float64) (*GeometryData, error) {
+	// Flip the Y axis, skip a closing point and make the polygon counter-clockwise
+	var polygon []*tinysvg.Pos
+	for i, p := range points {
+		if i == len(points)-1 && len(points) > 1 && *p == *points[0] {
+			break
+		}
+		polygon = append(polygon, tinysvg.NewPosf(p.X, -p.Y))
+	}
+	if len(polygon) < 3 {
+		return nil, errors.New("a polygon needs at least 3 points")
+	}
+	area := polygonArea(polygon)
+	if area == 0 {
+		return nil, errors.New("the polygon has no area")
+	}
+	if area < 0 {
+		for i, j := 0, len(polygon)-1; i < j; i, j = i+1, j-1 {
+			polygon[i], polygon[j] = polygon[j], polygon[i]
+		}
+	}
+	triangles, err := triangulate(polygon)
+	if err != nil {
+		return nil, err
+	}
+
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, p := range polygon {
+		minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+		maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+	}
+	u := func(p *tinysvg.Pos) float64 { return (p.X - minX) / math.Max(maxX-minX, 1e-9) }
+	v := func(p *tinysvg.Pos) float64 { return (p.Y - minY) / math.Max(maxY-minY, 1e-9) }
+
+	d := &GeometryData{}
+	// The front and back caps
+	for _, face := range []struct {
+		z      float64
+		normal Vector3
+	}{{depth, Vector3{0, 0, 1}}, {0, Vector3{0, 0, -1}}} {
+		first := uint32(d.VertexCount())
+		for _, p := range polygon {
+			d.addVertex(Vector3{p.X, p.Y, face.z}, face.normal, u(p), v(p))
+		}
+		for t := 0; t < len(triangles); t += 3 {
+			a, b, c := first+uint32(triangles[t]), first+uint32(triangles[t+1]), first+uint32(triangles[t+2])
+			if face.normal.Z < 0 {
+				b, c = c, b // the back faces the other way
+			}
+			d.Indices = append(d.Indices, a, b, c)
+		}
+	}
+	// The sides, with separate vertices for sharp edges
+	perimeter := 0.0
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		perimeter += math.Hypot(q.X-p.X, q.Y-p.Y)
+	}
+	distance := 0.0
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		normal := normalize(Vector3{q.Y - p.Y, p.X - q.X, 0})
+		u0, u1 := distance/perimeter, (distance+math.Hypot(q.X-p.X, q.Y-p.Y))/perimeter
+		distance += math.Hypot(q.X-p.X, q.Y-p.Y)
+		a := d.addVertex(Vector3{p.X, p.Y, 0}, normal, u0, 0)
+		b := d.addVertex(Vector3{q.X, q.Y, 0}, normal, u1, 0)
+		c := d.addVertex(Vector3{q.X, q.Y, depth}, normal, u1, 1)
+		e := d.addVertex(Vector3{p.X, p.Y, depth}, normal, u0, 1)
+		d.Indices = append(d.Indices, a, b, c, a, c, e)
+	}
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// NewGeometryFromData creates buffer geometry from vertex data that has been generated in Go
+func (s *Scene) NewGeometryFromData(d *GeometryData) (*Geometry, error) {
+	return s.NewBufferGeometry(d.Positions, d.Normals, d.UVs, d.Indices)
+}
+
+// NewGeometryFromData creates buffer geometry from vertex data that has been generated in Go
+func NewGeometryFromData(d *GeometryData) (*Geometry, error) {
+	return defaultScene.NewGeometryFromData(d)
+}
@@ -0,0 +1,111 @@
+package onthefly
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/xyproto/tinysvg"
+)
+
+func TestTerrain(t *testing.T) {
+	d, err := Terrain(10, 6, 4, 3, func(x, z float64) float64 { return 0 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.VertexCount() != 20 || d.TriangleCount() != 24 || len(d.UVs) != 40 || len(d.Normals) != 60 {
+		t.Errorf("Expected 20 vertices and 24 triangles, got %d and %d", d.VertexCount(), d.TriangleCount())
+	}
+	// A flat terrain faces up
+	for i := 0; i < len(d.Normals); i += 3 {
+		if d.Normals[i] != 0 || d.Normals[i+1] != 1 || d.Normals[i+2] != 0 {
+			t.Fatalf("Expected the normals to point up, got %v", d.Normals[i:i+3])
+		}
+	}
+	if p := d.position(0); p.X != -5 || p.Z != -3 {
+		t.Errorf("Expected the terrain to be centered, the first vertex is at %v", p)
+	}
+	if _, err := Terrain(1, 1, 0, 1, func(x, z float64) float64 { return 0 }); err == nil {
+		t.Error("Expected an error for zero segments")
+	}
+	if _, err := Terrain(1, 1, 1, 1, func(x, z float64) float64 { return math.NaN() }); err == nil {
+		t.Error("Expected an error for heights that are not numbers")
+	}
+}
+
+func TestTerrainFromImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	img.SetGray(2, 1, color.Gray{255})
+	d, err := TerrainFromImage(img, 2, 1, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.VertexCount() != 6 || d.TriangleCount() != 4 {
+		t.Errorf("Expected 6 vertices and 4 triangles, got %d and %d", d.VertexCount(), d.TriangleCount())
+	}
+	if p := d.position(5); p != (Vector3{1, 0.5, 0.5}) {
+		t.Errorf("Expected the white pixel to be at the max height, got %v", p)
+	}
+	if _, err := TerrainFromImage(image.NewGray(image.Rect(0, 0, 1, 5)), 1, 1, 1); err == nil {
+		t.Error("Expected an error for a too small image")
+	}
+}
+
+func TestParametricSurface(t *testing.T) {
+	sphere := func(u, v float64) Vector3 {
+		theta, phi := u*2*math.Pi, v*math.Pi
+		return Vector3{math.Sin(phi) * math.Cos(theta), math.Cos(phi), math.Sin(phi) * math.Sin(theta)}
+	}
+	d, err := ParametricSurface(sphere, 16, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.VertexCount() != 17*9 || d.TriangleCount() != 2*16*8 {
+		t.Errorf("Unexpected vertex and triangle counts: %d and %d", d.VertexCount(), d.TriangleCount())
+	}
+	g, err := NewScene().NewGeometryFromData(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The normals are generated in Go
+	if strings.Contains(g.JS, "computeVertexNormals") || !strings.Contains(g.JS, `setAttribute("normal"`) {
+		t.Errorf("Expected the normals to be included: %s", g.JS)
+	}
+}
+
+func TestExtrude(t *testing.T) {
+	star, err := tinysvg.PointsFromString("350,75 379,161 469,161 397,215 423,301 350,250 277,301 303,215 231,161 321,161")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Extrude(star, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10 points for each cap and 4 for each side, 8 triangles for each cap and 2 for each side
+	if d.VertexCount() != 60 || d.TriangleCount() != 36 {
+		t.Errorf("Expected 60 vertices and 36 triangles, got %d and %d", d.VertexCount(), d.TriangleCount())
+	}
+	// Every triangle faces the same way as its normals
+	for i := 0; i < len(d.Indices); i += 3 {
+		a, b, c := d.Indices[i], d.Indices[i+1], d.Indices[i+2]
+		n := cross(sub(d.position(b), d.position(a)), sub(d.position(c), d.position(a)))
+		if n.X*float64(d.Normals[3*a])+n.Y*float64(d.Normals[3*a+1])+n.Z*float64(d.Normals[3*a+2]) <= 0 {
+			t.Fatalf("Triangle %d faces the wrong way", i/3)
+		}
+	}
+	// A closed square in clockwise order
+	square, _ := tinysvg.PointsFromString("0,0 1,0 1,1 0,1 0,0")
+	if d, err := Extrude(square, 1); err != nil || d.VertexCount() != 24 || d.TriangleCount() != 12 {
+		t.Errorf("Expected a cube with 24 vertices and 12 triangles, got %v", err)
+	}
+	line, _ := tinysvg.PointsFromString("0,0 1,1 2,2")
+	if _, err := Extrude(line, 1); err == nil {
+		t.Error("Expected an error for a polygon without area")
+	}
+	if _, err := NewGeometryFromData(d); err != nil {
+		t.Error(err)
+	}
+}
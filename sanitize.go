@@ -0,0 +1,378 @@
+package onthefly
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// Policy is an allowlist of HTML elements, attributes and URL schemes, used when
+// sanitizing untrusted HTML. Elements that are not allowed are removed, but their
+// contents are kept, except for elements like <script> and <style>, which are
+// removed together with their contents. Event handler attributes, like "onclick",
+// are always removed, as are URLs with schemes that are not allowed, like "javascript:".
+type Policy struct {
+	// Elements is the list of allowed element names
+	Elements []string
+	// Attributes is the list of allowed attributes per element name.
+	// The attributes listed for "*" are allowed on all allowed elements.
+	Attributes map[string][]string
+	// URLSchemes is the list of allowed schemes in attributes that contain URLs,
+	// like "href" and "src". Relative URLs are always allowed.
+	URLSchemes []string
+}
+
+// DefaultPolicy returns a policy that allows basic formatting, links, images, lists and tables
+func DefaultPolicy() Policy {
+	return Policy{
+		Elements: []string{
+			"a", "abbr", "b", "blockquote", "br", "caption", "cite", "code", "dd", "del", "div", "dl",
+			"dt", "em", "figcaption", "figure", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "img",
+			"ins", "kbd", "li", "mark", "ol", "p", "pre", "q", "s", "small", "span", "strong", "sub",
+			"sup", "table", "tbody", "td", "tfoot", "th", "thead", "tr", "u", "ul",
+		},
+		Attributes: map[string][]string{
+			"*":   {"title", "class", "lang", "dir"},
+			"a":   {"href", "rel"},
+			"img": {"src", "alt", "width", "height"},
+			"ol":  {"start"},
+			"td":  {"colspan", "rowspan"},
+			"th":  {"colspan", "rowspan", "scope"},
+		},
+		URLSchemes: []string{"http", "https", "mailto"},
+	}
+}
+
+var (
+	// Elements that never have any content or closing tag
+	voidElements = []string{"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr"}
+	// Elements where the contents are text, up until the closing tag
+	rawTextElements = []string{"script", "style", "textarea", "title", "xmp", "iframe", "noembed", "noframes", "noscript"}
+	// Elements that are removed together with their contents, if they are not allowed
+	droppedElements = []string{"script", "style", "iframe", "object", "embed", "template", "noscript", "noembed", "noframes", "textarea", "title", "select", "head"}
+	// Attributes that contain URLs
+	urlAttributes = []string{"href", "src", "action", "formaction", "cite", "poster", "background", "longdesc", "srcset", "xlink:href", "data"}
+)
+
+// htmlNode is a node in a parsed HTML fragment. Text nodes have an empty name.
+type htmlNode struct {
+	name     string
+	attrs    [][2]string
+	text     string
+	children []*htmlNode
+}
+
+// isNameByte checks if the given byte can be part of a tag or attribute name
+func isNameByte(c byte) bool {
+	return c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != '/' && c != '>' && c != '='
+}
+
+// isHTMLSpace checks if the given byte is HTML whitespace
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isLetter checks if the given byte is an ASCII letter
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// indexFold returns the index of the first ASCII case-insensitive match of sub in s, or -1
+func indexFold(s, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseHTMLFragment parses an HTML fragment into a tree of nodes, in a forgiving way.
+// Comments, doctypes and processing instructions are skipped.
+func parseHTMLFragment(s string) *htmlNode {
+	root := &htmlNode{name: "#fragment"}
+	stack := []*htmlNode{root}
+	current := func() *htmlNode { return stack[len(stack)-1] }
+	addText := func(text string) {
+		if text == "" {
+			return
+		}
+		parent := current()
+		if n := len(parent.children); n > 0 && parent.children[n-1].name == "" {
+			parent.children[n-1].text += text
+			return
+		}
+		parent.children = append(parent.children, &htmlNode{text: text})
+	}
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			end := strings.IndexByte(s[i:], '<')
+			if end < 0 {
+				end = len(s) - i
+			}
+			addText(s[i : i+end])
+			i += end
+			continue
+		}
+		switch {
+		case strings.HasPrefix(s[i:], "<!--"):
+			end := strings.Index(s[i+4:], "-->")
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += 4 + end + 3
+			}
+		case strings.HasPrefix(s[i:], "<!") || strings.HasPrefix(s[i:], "<?"):
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += end + 1
+			}
+		case strings.HasPrefix(s[i:], "</") && i+2 < len(s) && isLetter(s[i+2]):
+			j := i + 2
+			for j < len(s) && isNameByte(s[j]) {
+				j++
+			}
+			name := strings.ToLower(s[i+2 : j])
+			end := strings.IndexByte(s[j:], '>')
+			if end < 0 {
+				i = len(s)
+			} else {
+				i = j + end + 1
+			}
+			// Close the element, and any elements that were left open inside of it
+			for k := len(stack) - 1; k > 0; k-- {
+				if stack[k].name == name {
+					stack = stack[:k]
+					break
+				}
+			}
+		case i+1 < len(s) && isLetter(s[i+1]):
+			j := i + 1
+			for j < len(s) && isNameByte(s[j]) {
+				j++
+			}
+			node := &htmlNode{name: strings.ToLower(s[i+1 : j])}
+			selfClosing := false
+			// Parse the attributes
+			for j < len(s) && s[j] != '>' {
+				if isHTMLSpace(s[j]) || s[j] == '/' {
+					selfClosing = s[j] == '/'
+					j++
+					continue
+				}
+				selfClosing = false
+				k := j
+				for j < len(s) && (isNameByte(s[j]) || (j == k && s[j] == '=')) {
+					j++
+				}
+				attrName := strings.ToLower(s[k:j])
+				for j < len(s) && isHTMLSpace(s[j]) {
+					j++
+				}
+				value := ""
+				if j < len(s) && s[j] == '=' {
+					j++
+					for j < len(s) && isHTMLSpace(s[j]) {
+						j++
+					}
+					if j < len(s) && (s[j] == '"' || s[j] == '\'') {
+						end := strings.IndexByte(s[j+1:], s[j])
+						if end < 0 {
+							end = len(s) - j - 1
+						}
+						value = s[j+1 : j+1+end]
+						j += end + 2
+					} else {
+						k := j
+						for j < len(s) && !isHTMLSpace(s[j]) && s[j] != '>' {
+							j++
+						}
+						value = s[k:j]
+					}
+				}
+				node.attrs = append(node.attrs, [2]string{attrName, html.UnescapeString(value)})
+			}
+			i = min(j+1, len(s))
+
+			// Some elements are implicitly closed when a new one is opened
+			switch node.name {
+			case "li", "p", "tr", "option", "dt", "dd":
+				if current().name == node.name || (node.name == "dt" && current().name == "dd") || (node.name == "dd" && current().name == "dt") {
+					stack = stack[:len(stack)-1]
+				}
+			case "td", "th":
+				if current().name == "td" || current().name == "th" {
+					stack = stack[:len(stack)-1]
+				}
+			}
+			current().children = append(current().children, node)
+
+			if slices.Contains(rawTextElements, node.name) {
+				// The contents are text, up to the closing tag
+				end := indexFold(s[i:], "</"+node.name)
+				if end < 0 {
+					end = len(s) - i
+				}
+				if end > 0 {
+					node.children = append(node.children, &htmlNode{text: s[i : i+end]})
+				}
+				i += end
+				if gt := strings.IndexByte(s[i:], '>'); gt >= 0 {
+					i += gt + 1
+				}
+				continue
+			}
+			if !selfClosing && !slices.Contains(voidElements, node.name) {
+				stack = append(stack, node)
+			}
+		default:
+			addText("<")
+			i++
+		}
+	}
+	return root
+}
+
+// allowsElement checks if the given element is allowed by the policy
+func (policy Policy) allowsElement(name string) bool {
+	return slices.Contains(policy.Elements, name)
+}
+
+// allowsAttribute checks if the given attribute and value are allowed on the given element
+func (policy Policy) allowsAttribute(element, name, value string) bool {
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+	if !slices.Contains(policy.Attributes[element], name) && !slices.Contains(policy.Attributes["*"], name) {
+		return false
+	}
+	if slices.Contains(urlAttributes, name) || name == "style" && strings.Contains(strings.ToLower(value), "url(") {
+		return policy.allowsURL(value)
+	}
+	if name == "style" {
+		v := strings.ToLower(value)
+		return !strings.Contains(v, "expression(") && !strings.Contains(v, "javascript:")
+	}
+	return true
+}
+
+// allowsURL checks if the given URL is relative or has a scheme that is allowed by the policy
+func (policy Policy) allowsURL(value string) bool {
+	// Browsers ignore whitespace and control characters when looking for the scheme
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+	colon := strings.IndexByte(cleaned, ':')
+	if colon < 0 || strings.ContainsAny(cleaned[:colon], "/?#") {
+		// A relative URL
+		return true
+	}
+	scheme := strings.ToLower(cleaned[:colon])
+	return slices.Contains(policy.URLSchemes, scheme)
+}
+
+// sanitizeNode converts a parsed node into tags, according to the policy
+func (policy Policy) sanitizeNode(n *htmlNode) []*Tag {
+	if n.name == "" {
+		return []*Tag{newTextNode(html.EscapeString(html.UnescapeString(n.text)))}
+	}
+	var children []*Tag
+	for _, child := range n.children {
+		children = append(children, policy.sanitizeNode(child)...)
+	}
+	if !policy.allowsElement(n.name) {
+		if slices.Contains(droppedElements, n.name) {
+			return nil
+		}
+		// Keep the contents of the element
+		return children
+	}
+	tag := NewTag(n.name)
+	for _, attr := range n.attrs {
+		if policy.allowsAttribute(n.name, attr[0], attr[1]) {
+			tag.AddAttrib(attr[0], html.EscapeString(attr[1]))
+		}
+	}
+	if slices.Contains(voidElements, n.name) {
+		return []*Tag{tag}
+	}
+	tag.setChildren(children)
+	if len(children) == 0 {
+		// Keep the element from being rendered as <name />
+		tag.AddContent(" ")
+	}
+	return []*Tag{tag}
+}
+
+// Sanitize parses an untrusted HTML fragment and returns the tags that are allowed by the policy.
+// Text is returned as text nodes. The returned tags can be added to other tags with AddChild.
+func Sanitize(fragment string, policy Policy) []*Tag {
+	var tags []*Tag
+	for _, n := range parseHTMLFragment(fragment).children {
+		tags = append(tags, policy.sanitizeNode(n)...)
+	}
+	return tags
+}
+
+// SanitizeHTML sanitizes an untrusted HTML fragment and returns it as HTML.
+// The result can be used with AddContent.
+func SanitizeHTML(fragment string, policy Policy) string {
+	var sb strings.Builder
+	for _, tag := range Sanitize(fragment, policy) {
+		sb.WriteString(getXMLRecursively(tag, false, 0))
+	}
+	return sb.String()
+}
+
+// SanitizeInPlace sanitizes the contents, attributes and children of a tag, recursively,
+// according to the policy. The tag itself is kept, even if it is not allowed by the policy,
+// but its attributes are filtered. Content that has been added with AddContent is parsed
+// and sanitized as HTML. A tag like <script> or <style>, that is not allowed by the policy
+// and whose contents are always dropped, is emptied and left without attributes.
+func (tag *Tag) SanitizeInPlace(policy Policy) {
+	if slices.Contains(droppedElements, tag.name) && !policy.allowsElement(tag.name) {
+		tag.attrs = make(map[string]string)
+		tag.content, tag.lastContent, tag.xmlContent = "", "", ""
+		tag.ClearChildren()
+		return
+	}
+	if tag.name != "" && tag.name[0] != '<' {
+		for name, value := range tag.attrs {
+			if value == noAttribute {
+				value = ""
+			}
+			if !policy.allowsAttribute(tag.name, name, html.UnescapeString(value)) {
+				delete(tag.attrs, name)
+			}
+		}
+	}
+	tag.content = SanitizeHTML(tag.content, policy)
+	tag.lastContent = SanitizeHTML(tag.lastContent, policy)
+	tag.xmlContent = ""
+	var children []*Tag
+	for _, child := range tag.GetChildren() {
+		if child.name != "" && !policy.allowsElement(child.name) {
+			if slices.Contains(droppedElements, child.name) {
+				continue
+			}
+			// Keep the contents of the child, but not the child itself
+			child.SanitizeInPlace(policy)
+			if child.content != "" {
+				children = append(children, newTextNode(child.content))
+			}
+			children = append(children, child.GetChildren()...)
+			if child.lastContent != "" {
+				children = append(children, newTextNode(child.lastContent))
+			}
+			continue
+		}
+		child.SanitizeInPlace(policy)
+		children = append(children, child)
+	}
+	tag.setChildren(children)
+}
@@ -0,0 +1,83 @@
+package onthefly
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	policy := DefaultPolicy()
+	tests := []struct {
+		fragment, expected string
+	}{
+		{"Hello <b>world</b>", "Hello <b>world</b>"},
+		{"<p onclick=\"alert(1)\">text</p>", "<p>text</p>"},
+		{"<script>alert(1)</script>after", "after"},
+		{"<SCRIPT>alert(1)</sCrIpT>after", "after"},
+		{"<a href=\"javascript:alert(1)\">x</a>", "<a>x</a>"},
+		{"<a href=\" jav&#x09;ascript:alert(1)\">x</a>", "<a>x</a>"},
+		{"<a href=\"https://example.com/?a=1&amp;b=2\">x</a>", "<a href=\"https://example.com/?a=1&amp;b=2\">x</a>"},
+		{"<a href=/relative>x</a>", "<a href=\"/relative\">x</a>"},
+		{"<img src=x onerror=alert(1)>", "<img src=\"x\" />"},
+		{"<blink>kept <i>text</i></blink>", "kept <i>text</i>"},
+		{"<style>body { display: none }</style>", ""},
+		{"<p>unclosed <b>bold", "<p>unclosed <b>bold</b></p>"},
+		{"<ul><li>one<li>two</ul>", "<ul><li>one</li><li>two</li></ul>"},
+		{"1 < 2 & 3 > 2", "1 &lt; 2 &amp; 3 &gt; 2"},
+		{"<!-- comment --><br>", "<br />"},
+		{"<span></span>", "<span> </span>"},
+		{"<p title='\"><script>'>x</p>", "<p title=\"&#34;&gt;&lt;script&gt;\">x</p>"},
+	}
+	for _, test := range tests {
+		if got := SanitizeHTML(test.fragment, policy); got != test.expected {
+			t.Errorf("Sanitize(%q): expected %q, got %q", test.fragment, test.expected, got)
+		}
+	}
+}
+
+func TestSanitizeInPlace(t *testing.T) {
+	div := NewTag("div")
+	div.AddAttrib("onmouseover", "steal()")
+	div.AddAttrib("class", "comment")
+	div.AddContent("<b>bold</b><script>steal()</script>")
+	iframe := div.AddNewTag("iframe")
+	iframe.AddAttrib("src", "https://evil.example.com")
+	font := div.AddNewTag("font")
+	font.AddTextNode("text in font")
+	a := div.AddNewTag("a")
+	a.AddAttrib("href", "JavaScript:steal()")
+	a.AddContent("link")
+
+	div.SanitizeInPlace(DefaultPolicy())
+
+	s := div.String()
+	for _, unexpected := range []string{"steal", "iframe", "<font", "href"} {
+		if strings.Contains(s, unexpected) {
+			t.Errorf("Did not expect %q in:\n%s", unexpected, s)
+		}
+	}
+	for _, expected := range []string{"class=\"comment\"", "<b>bold</b>", "text in font", "<a>link</a>"} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, s)
+		}
+	}
+
+	// A disallowed root tag is kept, but emptied
+	script := NewTag("script")
+	script.AddAttrib("src", "https://evil.example.com/steal.js")
+	script.AddContent("steal()")
+	script.AddNewTag("b").AddContent("steal()")
+	script.SanitizeInPlace(DefaultPolicy())
+	if s := script.String(); strings.Contains(s, "steal") {
+		t.Errorf("Expected the script to be emptied: %s", s)
+	}
+
+	// The sanitized tags can be added to other tags
+	body := NewTag("body")
+	for _, tag := range Sanitize("<em>safe</em><script>unsafe()</script>", DefaultPolicy()) {
+		body.AddChild(tag)
+	}
+	if body.CountChildren() != 1 || body.FindChildByName("em") == nil {
+		t.Errorf("Expected only the em tag to be added:\n%s", body.String())
+	}
+}
@@ -0,0 +1,488 @@
+package onthefly
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+type (
+	// Scene generates the JavaScript for a Three.JS scene.
+	// Every scene has its own IDs for the variables, so the generated script is the same
+	// every time, and a registry of the geometries, textures, materials, meshes, groups,
+	// models, lights, cameras, controls and renderers that it has created. Elements are
+	// declared in the order they are created, and the code is generated when the scene is
+	// rendered, so that elements can be changed (with SetPosition and so on) after they have
+	// been created or added.
+	Scene struct {
+		ID         string // name of the scene variable
+		mu         sync.Mutex
+		global     bool // elements are not registered, and meshes include their geometry and material
+		counters   map[string]int
+		entries    []sceneEntry
+		owned      map[*Element]bool
+		geometries []*Geometry
+		materials  []*Material
+		meshes     []*Mesh
+		lights     []*Light
+		cameras    []*Camera
+		renderers  []*Renderer
+		groups     []*Group
+		textures   []*Texture
+		models     []*Model
+		controls   []*Controls
+		animations []*Animation
+	}
+	// sceneEntry is a piece of the generated script: an element that is declared and/or
+	// added to the scene, or JavaScript code
+	sceneEntry struct {
+		element *Element
+		declare bool
+		add     bool
+		code    string
+		render  *RenderFunc // a render function, that the controls, animations and picking are added to
+	}
+)
+
+// defaultScene provides the IDs for NewMesh, NewBoxGeometry and the other functions
+// that create elements without a Scene. It is shared by the whole process, so the IDs
+// that are handed out, like "m0" or "m3", depend on what has been created before.
+// Use NewScene when the generated code must be the same every time.
+var defaultScene = &Scene{ID: "scene", global: true}
+
+// NewScene creates a new, empty scene, that is stored in the "scene" variable
+func NewScene() *Scene {
+	return &Scene{ID: "scene"}
+}
+
+// nextID returns a new variable name with the given prefix
+func (s *Scene) nextID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]int)
+	}
+	id := prefix + strconv.Itoa(s.counters[prefix])
+	s.counters[prefix]++
+	return id
+}
+
+// register declares the given element, unless this is the default scene
+func (s *Scene) register(e *Element) {
+	if s.global {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owned == nil {
+		s.owned = make(map[*Element]bool)
+	}
+	s.owned[e] = true
+	s.entries = append(s.entries, sceneEntry{element: e, declare: true})
+}
+
+// owns checks if the given element was created by this scene
+func (s *Scene) owns(e *Element) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.owned[e]
+}
+
+// addEntry adds a piece of the generated script
+func (s *Scene) addEntry(entry sceneEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+}
+
+// NewBoxGeometry creates geometry for a box
+func (s *Scene) NewBoxGeometry(w, h, d float64) *Geometry {
+	id := s.nextID(geometryPrefix)
+	return s.addGeometry(&Geometry{ID: id, JS: newThree(id, "BoxGeometry", nums(w, h, d)...)})
+}
+
+// NewSphereGeometry creates geometry for a sphere
+func (s *Scene) NewSphereGeometry(radius float64, widthSegments, heightSegments int) (*Geometry, error) {
+	if err := checkSegments(widthSegments, heightSegments); err != nil {
+		return nil, err
+	}
+	id := s.nextID(geometryPrefix)
+	return s.addGeometry(&Geometry{ID: id, JS: newThree(id, "SphereGeometry", js.Value(radius), js.Value(widthSegments), js.Value(heightSegments))}), nil
+}
+
+// NewPlaneGeometry creates geometry for a plane
+func (s *Scene) NewPlaneGeometry(width, height float64) *Geometry {
+	id := s.nextID(geometryPrefix)
+	return s.addGeometry(&Geometry{ID: id, JS: newThree(id, "PlaneGeometry", nums(width, height)...)})
+}
+
+// NewCylinderGeometry creates geometry for a cylinder
+func (s *Scene) NewCylinderGeometry(radiusTop, radiusBottom, height float64, radialSegments int) (*Geometry, error) {
+	if err := checkSegments(radialSegments); err != nil {
+		return nil, err
+	}
+	id := s.nextID(geometryPrefix)
+	return s.addGeometry(&Geometry{ID: id, JS: newThree(id, "CylinderGeometry", js.Value(radiusTop), js.Value(radiusBottom), js.Value(height), js.Value(radialSegments))}), nil
+}
+
+// addGeometry registers the given geometry
+func (s *Scene) addGeometry(g *Geometry) *Geometry {
+	s.register((*Element)(g))
+	if !s.global {
+		s.mu.Lock()
+		s.geometries = append(s.geometries, g)
+		s.mu.Unlock()
+	}
+	return g
+}
+
+// NewMaterial creates a very simple type of material
+func (s *Scene) NewMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return s.newMaterial("MeshBasicMaterial", color, opts)
+}
+
+// NewNormalMaterial creates a material which reflects the normals of the geometry
+func (s *Scene) NewNormalMaterial() *Material {
+	id := s.nextID(materialPrefix)
+	return s.addMaterial(&Material{ID: id, JS: newThree(id, "MeshNormalMaterial")})
+}
+
+// NewLambertMaterial creates a Lambert material (responds to lighting)
+func (s *Scene) NewLambertMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return s.newMaterial("MeshLambertMaterial", color, opts)
+}
+
+// NewPhongMaterial creates a Phong material (supports shiny surfaces)
+func (s *Scene) NewPhongMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return s.newMaterial("MeshPhongMaterial", color, opts)
+}
+
+// NewStandardMaterial creates a standard material (physically based)
+func (s *Scene) NewStandardMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return s.newMaterial("MeshStandardMaterial", color, opts)
+}
+
+// addMaterial registers the given material
+func (s *Scene) addMaterial(m *Material) *Material {
+	s.register((*Element)(m))
+	if !s.global {
+		s.mu.Lock()
+		s.materials = append(s.materials, m)
+		s.mu.Unlock()
+	}
+	return m
+}
+
+// NewMesh creates a new mesh, given geometry and material.
+// Geometry and material from other scenes are declared together with the mesh.
+func (s *Scene) NewMesh(geometry *Geometry, material *Material) *Mesh {
+	id := s.nextID(meshPrefix)
+	var code string
+	if !s.owns((*Element)(geometry)) {
+		code += geometry.JS
+	}
+	if !s.owns((*Element)(material)) {
+		code += material.JS
+	}
+	code += newThree(id, "Mesh", js.Ident(geometry.ID), js.Ident(material.ID))
+	mesh := &Mesh{ID: id, JS: code}
+	s.register((*Element)(mesh))
+	if !s.global {
+		s.mu.Lock()
+		s.meshes = append(s.meshes, mesh)
+		s.mu.Unlock()
+	}
+	return mesh
+}
+
+// NewAmbientLight creates an ambient light that illuminates all objects equally
+func (s *Scene) NewAmbientLight(color string, intensity float64) (*Light, error) {
+	c, err := threeColor(color)
+	if err != nil {
+		return nil, err
+	}
+	id := s.nextID(lightPrefix)
+	return s.addLight(&Light{ID: id, JS: newThree(id, "AmbientLight", c, js.Value(intensity))}), nil
+}
+
+// NewDirectionalLight creates a directional light (like sunlight)
+func (s *Scene) NewDirectionalLight(color string, intensity float64) (*Light, error) {
+	c, err := threeColor(color)
+	if err != nil {
+		return nil, err
+	}
+	id := s.nextID(lightPrefix)
+	return s.addLight(&Light{ID: id, JS: newThree(id, "DirectionalLight", c, js.Value(intensity))}), nil
+}
+
+// NewPointLight creates a point light (like a light bulb)
+func (s *Scene) NewPointLight(color string, intensity, distance float64) (*Light, error) {
+	c, err := threeColor(color)
+	if err != nil {
+		return nil, err
+	}
+	id := s.nextID(lightPrefix)
+	return s.addLight(&Light{ID: id, JS: newThree(id, "PointLight", c, js.Value(intensity), js.Value(distance))}), nil
+}
+
+// addLight registers the given light
+func (s *Scene) addLight(l *Light) *Light {
+	s.register((*Element)(l))
+	if !s.global {
+		s.mu.Lock()
+		s.lights = append(s.lights, l)
+		s.mu.Unlock()
+	}
+	return l
+}
+
+// NewPerspectiveCamera creates a new perspective camera with custom parameters
+func (s *Scene) NewPerspectiveCamera(fov, aspect, near, far float64) *Camera {
+	id := s.nextID(cameraPrefix)
+	return s.addCamera(&Camera{ID: id, JS: newThree(id, "PerspectiveCamera", nums(fov, aspect, near, far)...)})
+}
+
+// NewOrthographicCamera creates a new orthographic camera
+func (s *Scene) NewOrthographicCamera(left, right, top, bottom, near, far float64) *Camera {
+	id := s.nextID(cameraPrefix)
+	return s.addCamera(&Camera{ID: id, JS: newThree(id, "OrthographicCamera", nums(left, right, top, bottom, near, far)...)})
+}
+
+// addCamera registers the given camera
+func (s *Scene) addCamera(c *Camera) *Camera {
+	s.register((*Element)(c))
+	if !s.global {
+		s.mu.Lock()
+		s.cameras = append(s.cameras, c)
+		s.mu.Unlock()
+	}
+	return c
+}
+
+// NewWebGLRenderer creates a new WebGL renderer with custom options
+func (s *Scene) NewWebGLRenderer(antialias bool) *Renderer {
+	id := s.nextID(rendererPrefix)
+	code := newThree(id, "WebGLRenderer", js.Object{"antialias": js.Value(antialias)})
+	code += call(id, "setSize", js.Ident("window.innerWidth"), js.Ident("window.innerHeight"))
+	code += call("document.body", "appendChild", js.Ident(id+".domElement"))
+	r := &Renderer{ID: id, JS: code}
+	s.register((*Element)(r))
+	if !s.global {
+		s.mu.Lock()
+		s.renderers = append(s.renderers, r)
+		s.mu.Unlock()
+	}
+	return r
+}
+
+// NewGroup creates a new, empty group
+func (s *Scene) NewGroup() *Group {
+	id := s.nextID(groupPrefix)
+	g := &Group{ID: id, JS: newThree(id, "Group")}
+	s.register((*Element)(g))
+	if !s.global {
+		s.mu.Lock()
+		s.groups = append(s.groups, g)
+		s.mu.Unlock()
+	}
+	return g
+}
+
+// Add adds meshes, groups or other objects to the scene
+func (s *Scene) Add(objects ...Object) {
+	for _, o := range objects {
+		s.AddElement(o.Element())
+	}
+}
+
+// AddLight adds a light to the scene
+func (s *Scene) AddLight(light *Light) {
+	s.AddElement((*Element)(light))
+}
+
+// AddElement adds any Three.js element to the scene.
+// Elements from other scenes are declared when they are added.
+func (s *Scene) AddElement(e *Element) {
+	s.addEntry(sceneEntry{element: e, declare: !s.owns(e), add: true})
+}
+
+// AddJS adds JavaScript code to the generated script, after the elements that have been created so far
+func (s *Scene) AddJS(code string) {
+	s.addEntry(sceneEntry{code: code})
+}
+
+// AddStmt adds statements to the generated script, after the elements that have been created so far
+func (s *Scene) AddStmt(stmts ...js.Stmt) {
+	s.AddJS(js.Join(stmts...))
+}
+
+// NewRenderFunction creates a new render function for the scene, which is called at every
+// animation frame and renders the scene with the given renderer and camera
+func (s *Scene) NewRenderFunction(renderer *Renderer, camera *Camera) *RenderFunc {
+	return newRenderFunction(s.ID, renderer.ID, camera.ID)
+}
+
+// AddRenderFunction adds a render function to the generated script.
+// If call is true, the render function is called right after it has been defined.
+// The controls of the scene that move the camera of the render function are updated
+// at every frame.
+func (s *Scene) AddRenderFunction(r *RenderFunc, call bool) {
+	s.addEntry(sceneEntry{render: r})
+	if call {
+		s.AddJS("render();")
+	}
+}
+
+// AddResizeHandler adds a window resize handler that keeps the given camera and renderer
+// in sync with the size of the window
+func (s *Scene) AddResizeHandler(camera *Camera, renderer *Renderer) {
+	s.AddStmt(resizeHandler(camera, renderer))
+}
+
+// AddTestCube adds a test cube to the scene
+func (s *Scene) AddTestCube() *Mesh {
+	cube := s.NewMesh(s.NewBoxGeometry(1, 1, 1), s.NewNormalMaterial())
+	s.Add(cube)
+	return cube
+}
+
+// Geometries returns the geometries that have been created by the scene
+func (s *Scene) Geometries() []*Geometry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Geometry(nil), s.geometries...)
+}
+
+// Materials returns the materials that have been created by the scene
+func (s *Scene) Materials() []*Material {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Material(nil), s.materials...)
+}
+
+// Meshes returns the meshes that have been created by the scene
+func (s *Scene) Meshes() []*Mesh {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Mesh(nil), s.meshes...)
+}
+
+// Lights returns the lights that have been created by the scene
+func (s *Scene) Lights() []*Light {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Light(nil), s.lights...)
+}
+
+// Cameras returns the cameras that have been created by the scene
+func (s *Scene) Cameras() []*Camera {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Camera(nil), s.cameras...)
+}
+
+// Renderers returns the renderers that have been created by the scene
+func (s *Scene) Renderers() []*Renderer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Renderer(nil), s.renderers...)
+}
+
+// Groups returns the groups that have been created by the scene
+func (s *Scene) Groups() []*Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Group(nil), s.groups...)
+}
+
+// String returns the JavaScript code for the scene
+func (s *Scene) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var sb strings.Builder
+	sb.WriteString(newThree(s.ID, "Scene"))
+	var (
+		declared = make(map[*Element]bool)
+		order    []*Element
+		declare  func(e *Element)
+	)
+	// declare writes the code for an element, and adds it to its parents and its children
+	// to it, as soon as both have been declared. Children from other scenes are declared
+	// together with their parent.
+	declare = func(e *Element) {
+		if declared[e] {
+			return
+		}
+		declared[e] = true
+		sb.WriteString(e.JS)
+		for _, parent := range order {
+			for _, child := range parent.children {
+				if child == e {
+					sb.WriteString(call(parent.ID, "add", js.Ident(e.ID)))
+				}
+			}
+		}
+		order = append(order, e)
+		for _, child := range e.children {
+			if declared[child] {
+				sb.WriteString(call(e.ID, "add", js.Ident(child.ID)))
+			} else if !s.owned[child] {
+				declare(child)
+			}
+		}
+	}
+	picking := false
+	for _, entry := range s.entries {
+		if entry.element != nil && entry.element.hasPicking() {
+			picking = true
+		}
+	}
+	for _, entry := range s.entries {
+		if entry.declare {
+			declare(entry.element)
+		}
+		if entry.add {
+			sb.WriteString(call(s.ID, "add", js.Ident(entry.element.ID)))
+		}
+		if entry.render != nil {
+			r := *entry.render
+			r.setup = append([]string(nil), r.setup...)
+			r.updates = append([]string(nil), r.updates...)
+			r.added = append([]string(nil), r.added...)
+			r.animations = append([]*Animation(nil), r.animations...)
+			// s.mu is held, so the controls and animations can be read directly
+			for _, c := range s.controls {
+				if c.camera == r.cameraID {
+					r.AddControls(c)
+				}
+			}
+			r.AddAnimations(s.animations...)
+			if picking {
+				r.AddPicking()
+			}
+			sb.WriteString(r.String())
+		}
+		sb.WriteString(entry.code)
+	}
+	return sb.String()
+}
+
+// AddScene adds the JavaScript code for the given scene to the end of the body.
+// The page must include Three.JS, and the scene should be complete.
+// The model loaders and the camera controls are included if the scene uses them.
+func (page *Page) AddScene(scene *Scene) (*Tag, error) {
+	code := scene.String()
+	if usesThreeLoaders(code) {
+		if err := page.UseThreeLoaders(); err != nil {
+			return nil, err
+		}
+	}
+	if usesThreeControls(code) {
+		if err := page.UseThreeControls(); err != nil {
+			return nil, err
+		}
+	}
+	return page.AddScriptToBody(code)
+}
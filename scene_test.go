@@ -0,0 +1,204 @@
+package onthefly
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func buildTestScene() *Scene {
+	scene := NewScene()
+	box := scene.NewBoxGeometry(1, 2, 3)
+	material, _ := scene.NewLambertMaterial("0xff0000")
+	first := scene.NewMesh(box, material)
+	second := scene.NewMesh(box, material)
+	scene.Add(first)
+	scene.Add(second)
+	// Changes after adding are included
+	(*Element)(second).SetPosition(2, 0, 0)
+	light, _ := scene.NewAmbientLight("0x404040", 1)
+	scene.AddLight(light)
+	scene.NewPerspectiveCamera(75, 1.5, 0.1, 1000)
+	return scene
+}
+
+func TestScene(t *testing.T) {
+	s := buildTestScene().String()
+	expected := "var scene = new THREE.Scene();" +
+		"var g0 = new THREE.BoxGeometry(1, 2, 3);" +
+		"var ma0 = new THREE.MeshLambertMaterial({color: 0xff0000});" +
+		"var m0 = new THREE.Mesh(g0, ma0);" +
+		"var m1 = new THREE.Mesh(g0, ma0);m1.position.set(2, 0, 0);" +
+		"scene.add(m0);scene.add(m1);" +
+		"var light0 = new THREE.AmbientLight(0x404040, 1);scene.add(light0);" +
+		"var cam0 = new THREE.PerspectiveCamera(75, 1.5, 0.1, 1000);"
+	if s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+	if again := buildTestScene().String(); again != s {
+		t.Errorf("Expected the same script for the same scene:\n%s\n%s", s, again)
+	}
+}
+
+func TestSceneRegistry(t *testing.T) {
+	scene := buildTestScene()
+	if n := len(scene.Geometries()); n != 1 {
+		t.Errorf("Expected 1 geometry, got %d", n)
+	}
+	if n := len(scene.Meshes()); n != 2 {
+		t.Errorf("Expected 2 meshes, got %d", n)
+	}
+	if n := len(scene.Materials()) + len(scene.Lights()) + len(scene.Cameras()); n != 3 {
+		t.Errorf("Expected a material, a light and a camera, got %d elements", n)
+	}
+	if len(scene.Renderers()) != 0 {
+		t.Error("Did not expect any renderers")
+	}
+
+	// Elements that were not created by the scene are declared where they are used
+	foreign := NewBoxGeometry(1, 1, 1)
+	mesh := scene.NewMesh(foreign, scene.Materials()[0])
+	scene.Add(mesh)
+	if s := scene.String(); !strings.Contains(s, foreign.JS+"var m2 = ") {
+		t.Errorf("Expected the geometry to be declared with the mesh:\n%s", s)
+	}
+}
+
+func TestDefaultSceneConcurrency(t *testing.T) {
+	var wg sync.WaitGroup
+	ids := make(chan string, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids <- NewMesh(NewBoxGeometry(1, 1, 1), NewNormalMaterial()).ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[string]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("Expected unique IDs, got %s twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestThreeJSValidation(t *testing.T) {
+	_, three := NewThreeJS("Validation")
+	if err := three.CameraPos("w", 1); err == nil {
+		t.Error("Expected an error for an invalid axis")
+	}
+	if err := three.CameraPos("z", 5); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewSphereGeometry(1, -1, 8); err == nil {
+		t.Error("Expected an error for a negative number of segments")
+	}
+	if _, err := NewCylinderGeometry(1, 1, 2, 16); err != nil {
+		t.Error(err)
+	}
+	for color, expected := range map[string]string{
+		"0xff0000":   "0xff0000",
+		"16711680":   "16711680",
+		"#f00":       `"#f00"`,
+		`"red"`:      `"red"`,
+		"'skyblue'":  `"skyblue"`,
+		"rgb(1,2,3)": `"rgb(1,2,3)"`,
+	} {
+		material, err := NewMaterial(color)
+		if err != nil {
+			t.Errorf("Expected %s to be a valid color: %v", color, err)
+			continue
+		}
+		if !strings.Contains(material.JS, "{color: "+expected+"}") {
+			t.Errorf("Expected the color %s in %s", expected, material.JS)
+		}
+	}
+	for _, color := range []string{"", "0xff0000); alert(1", "#ff00", `"red`, "red; x"} {
+		if _, err := NewPointLight(color, 1, 100); err == nil {
+			t.Errorf("Expected an error for the color %q", color)
+		}
+	}
+}
+
+func TestRenderFunctionFor(t *testing.T) {
+	scene := NewScene()
+	camera := scene.NewPerspectiveCamera(75, 1, 0.1, 100)
+	renderer := scene.NewWebGLRenderer(true)
+	r := scene.NewRenderFunction(renderer, camera)
+	r.AddJS("m0.rotation.x += 0.01;")
+	scene.AddRenderFunction(r, true)
+	scene.AddResizeHandler(camera, renderer)
+	s := scene.String()
+	for _, expected := range []string{
+		"renderer0.render(scene, cam0); };render();",
+		"cam0.aspect = window.innerWidth / window.innerHeight;",
+		"renderer0.setSize(window.innerWidth, window.innerHeight);",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in:\n%s", expected, s)
+		}
+	}
+
+	_, three := NewThreeJS("Globals")
+	three.AddRenderFunction(NewRenderFunctionFor(&Renderer{ID: "r"}, three.AddCamera()), false)
+	if s := three.GetContent(); !strings.Contains(s, "r.render(scene, camera);") {
+		t.Errorf("Expected the given renderer and camera to be used:\n%s", s)
+	}
+}
+
+func TestSceneGroups(t *testing.T) {
+	scene := NewScene()
+	group := scene.NewGroup()
+	scene.Add(group)
+	group.SetPosition(0, 1, 0)
+	box := scene.NewBoxGeometry(1, 1, 1)
+	material := scene.NewNormalMaterial()
+	parent := scene.NewMesh(box, material)
+	child := scene.NewMesh(box, material)
+	// The child is created after the parent, and added before it is declared
+	parent.Add(child)
+	group.Add(parent, group)
+	child.SetScale(0.5, 0.5, 0.5)
+	expected := "var scene = new THREE.Scene();" +
+		"var grp0 = new THREE.Group();grp0.position.set(0, 1, 0);" +
+		"scene.add(grp0);" +
+		"var g0 = new THREE.BoxGeometry(1, 1, 1);" +
+		"var ma0 = new THREE.MeshNormalMaterial();" +
+		"var m0 = new THREE.Mesh(g0, ma0);grp0.add(m0);" +
+		"var m1 = new THREE.Mesh(g0, ma0);m1.scale.set(0.5, 0.5, 0.5);m0.add(m1);"
+	if s := scene.String(); s != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, s)
+	}
+	if len(scene.Groups()) != 1 || len((*Element)(group).Children()) != 1 {
+		t.Error("Expected one group with one child, since a group can not be added to itself")
+	}
+
+	// Children from other scenes are declared together with their parent
+	foreign := NewMesh(NewBoxGeometry(1, 1, 1), NewNormalMaterial())
+	other := scene.NewGroup()
+	other.Add(foreign)
+	scene.Add(other)
+	s := scene.String()
+	if !strings.HasSuffix(s, "var grp1 = new THREE.Group();"+foreign.JS+"grp1.add("+foreign.ID+");scene.add(grp1);") {
+		t.Errorf("Expected the foreign mesh to be declared and added to the group:\n%s", s)
+	}
+}
+
+func TestAddGroupToScene(t *testing.T) {
+	_, three := NewThreeJS()
+	group := NewGroup()
+	mesh := NewMesh(NewBoxGeometry(1, 1, 1), NewNormalMaterial())
+	group.Add(mesh)
+	group.SetRotation(0, 1.5, 0)
+	three.AddGroupToScene(group)
+	s := three.String()
+	declare := strings.Index(s, "var "+mesh.ID+" = ")
+	add := strings.Index(s, group.ID+".add("+mesh.ID+");")
+	if declare < 0 || add < declare || !strings.Contains(s, group.ID+".rotation.set(0, 1.5, 0);") ||
+		!strings.Contains(s, "scene.add("+group.ID+");") {
+		t.Errorf("Expected the mesh to be declared and added to the group:\n%s", s)
+	}
+}
@@ -0,0 +1,340 @@
+package onthefly
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The struct tag key that is used by TableFromSlice and FormFromStruct.
+// The tag has the form `onthefly:"label,format,option,option..."`, where
+// format is a fmt verb like "%.2f" or, for time.Time, a time layout.
+// A label of "-" skips the field. The options "required" and "type=..."
+// are used when generating forms.
+const structTagKey = "onthefly"
+
+// TableOptions configures how TableFromSlice renders a table
+type TableOptions struct {
+	ID      string   // id attribute of the <table>
+	Class   string   // class attribute of the <table>
+	Caption string   // optional <caption>
+	Columns []string // optional list of Go field names to include, in order
+}
+
+// structField describes a struct field and the contents of its struct tag
+type structField struct {
+	index    int
+	name     string // Go field name
+	label    string
+	format   string
+	required bool
+	kind     string // overridden input type, if any
+}
+
+// structFields returns the exported fields of the given struct type, with labels and formats
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		sf := structField{index: i, name: f.Name, label: f.Name}
+		if tag, ok := f.Tag.Lookup(structTagKey); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				sf.label = parts[0]
+			}
+			if len(parts) > 1 {
+				sf.format = parts[1]
+			}
+			for _, option := range parts[min(len(parts), 2):] {
+				switch {
+				case option == "required":
+					sf.required = true
+				case strings.HasPrefix(option, "type="):
+					sf.kind = strings.TrimPrefix(option, "type=")
+				}
+			}
+		}
+		fields = append(fields, sf)
+	}
+	return fields
+}
+
+// formatValue formats a struct field value for display, using the given format, if any
+func formatValue(v reflect.Value, format string) string {
+	if t, ok := v.Interface().(time.Time); ok {
+		if format == "" {
+			format = time.RFC3339
+		}
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(format)
+	}
+	if k := v.Kind(); k == reflect.Pointer || k == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		return formatValue(v.Elem(), format)
+	}
+	if format == "" {
+		format = "%v"
+	}
+	return fmt.Sprintf(format, v.Interface())
+}
+
+// TableFromSlice adds a <table> with a <thead> and a <tbody> to the parent tag,
+// with one row per element of the given slice of structs (or pointers to structs).
+// opts may be nil. Returns the <table> tag.
+func TableFromSlice(parent *Tag, rows any, opts *TableOptions) (*Tag, error) {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.New("TableFromSlice needs a slice of structs")
+	}
+	t := v.Type().Elem()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("TableFromSlice needs a slice of structs")
+	}
+	if opts == nil {
+		opts = &TableOptions{}
+	}
+	fields := structFields(t)
+	if len(opts.Columns) > 0 {
+		byName := make(map[string]structField, len(fields))
+		for _, f := range fields {
+			byName[f.name] = f
+		}
+		fields = fields[:0]
+		for _, name := range opts.Columns {
+			f, ok := byName[name]
+			if !ok {
+				return nil, errors.New("no such column: " + name)
+			}
+			fields = append(fields, f)
+		}
+	}
+
+	table := parent.AddNewTag("table")
+	if opts.ID != "" {
+		table.AddAttrib("id", opts.ID)
+	}
+	if opts.Class != "" {
+		table.AddAttrib("class", opts.Class)
+	}
+	if opts.Caption != "" {
+		table.AddNewTag("caption").AddContent(html.EscapeString(opts.Caption))
+	}
+	tr := table.AddNewTag("thead").AddNewTag("tr")
+	for _, f := range fields {
+		th := tr.AddNewTag("th")
+		th.AddAttrib("scope", "col")
+		th.AddContent(html.EscapeString(f.label))
+	}
+	tbody := table.AddNewTag("tbody")
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		if row.Kind() == reflect.Pointer {
+			if row.IsNil() {
+				continue
+			}
+			row = row.Elem()
+		}
+		tr := tbody.AddNewTag("tr")
+		for _, f := range fields {
+			td := tr.AddNewTag("td")
+			text := formatValue(row.Field(f.index), f.format)
+			if text == "" {
+				// Keep empty cells from being rendered as <td />
+				text = "&nbsp;"
+			} else {
+				text = html.EscapeString(text)
+			}
+			td.AddContent(text)
+		}
+	}
+	return table, nil
+}
+
+// inputType returns the type of <input> to use for a struct field of the given type
+func inputType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "date"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "checkbox"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Pointer:
+		return inputType(t.Elem())
+	}
+	return "text"
+}
+
+// structValue returns the struct that v points to
+func structValue(v any) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return rv, errors.New("expected a struct, got a nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, errors.New("expected a struct or a pointer to a struct")
+	}
+	return rv, nil
+}
+
+// NewFormFromStruct creates a Form with one field per exported field of the given struct.
+// The input types are derived from the field types, and the current values are filled in.
+// The field names are the lowercase Go field names.
+func NewFormFromStruct(action, method string, v any) (*Form, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	form := NewForm(action, method)
+	for _, f := range structFields(rv.Type()) {
+		fv := rv.Field(f.index)
+		field := &Field{
+			Name:     strings.ToLower(f.name),
+			Label:    f.label,
+			Type:     inputType(fv.Type()),
+			Required: f.required,
+		}
+		if f.kind != "" {
+			field.Type = f.kind
+		}
+		switch {
+		case field.Type == "checkbox":
+			field.Checked = fv.Kind() == reflect.Bool && fv.Bool()
+		case field.Type == "date" && f.format == "":
+			field.Value = formatValue(fv, "2006-01-02")
+		default:
+			field.Value = formatValue(fv, f.format)
+		}
+		if k := fv.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+			// Let decimal numbers be entered
+			field.Step = "any"
+		}
+		form.AddField(field)
+	}
+	return form, nil
+}
+
+// FormFromStruct adds a form for the given struct to the parent tag.
+// The form is submitted to the current URL with POST.
+// Returns the <form> tag.
+func FormFromStruct(parent *Tag, v any) (*Tag, error) {
+	form, err := NewFormFromStruct("", "POST", v)
+	if err != nil {
+		return nil, err
+	}
+	return parent.AddForm(form), nil
+}
+
+// Decode stores the current field values of the form in the given pointer to a struct,
+// matching form fields with lowercase Go field names. This is typically used after Bind.
+func (form *Form) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("Decode needs a non-nil pointer to a struct")
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	for _, f := range structFields(rv.Type()) {
+		field := form.Field(strings.ToLower(f.name))
+		if field == nil {
+			continue
+		}
+		if err := setValue(rv.Field(f.index), field, f.format); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// setValue parses the value of a form field into a struct field
+func setValue(fv reflect.Value, field *Field, format string) error {
+	if fv.Type() == reflect.TypeOf(time.Time{}) {
+		if field.Value == "" {
+			fv.Set(reflect.ValueOf(time.Time{}))
+			return nil
+		}
+		layout := format
+		if layout == "" {
+			layout = "2006-01-02"
+		}
+		t, err := time.Parse(layout, field.Value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Pointer:
+		if field.Value == "" && fv.Type().Elem().Kind() != reflect.Bool {
+			// An empty field leaves an optional value unset
+			fv.Set(reflect.Zero(fv.Type()))
+			return nil
+		}
+		target := reflect.New(fv.Type().Elem())
+		if err := setValue(target.Elem(), field, format); err != nil {
+			return err
+		}
+		fv.Set(target)
+	case reflect.String:
+		fv.SetString(field.Value)
+	case reflect.Bool:
+		fv.SetBool(field.Checked)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Value == "" {
+			fv.SetInt(0)
+			return nil
+		}
+		x, err := strconv.ParseInt(field.Value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if field.Value == "" {
+			fv.SetUint(0)
+			return nil
+		}
+		x, err := strconv.ParseUint(field.Value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		if field.Value == "" {
+			fv.SetFloat(0)
+			return nil
+		}
+		x, err := strconv.ParseFloat(field.Value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(x)
+	}
+	return nil
+}
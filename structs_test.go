@@ -0,0 +1,162 @@
+package onthefly
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testProduct struct {
+	Name     string    `onthefly:"Product name,,required"`
+	Price    float64   `onthefly:"Price,%.2f"`
+	Stock    int       `onthefly:"In stock"`
+	Active   bool      `onthefly:"Active"`
+	Added    time.Time `onthefly:"Added,2006-01-02"`
+	Email    string    `onthefly:"Contact,,type=email"`
+	Internal string    `onthefly:"-"`
+	secret   string
+}
+
+func TestTableFromSlice(t *testing.T) {
+	products := []testProduct{
+		{Name: "<Widget>", Price: 3.5, Stock: 7, Added: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{Name: "Gadget", Price: 10, Internal: "hidden", secret: "hidden"},
+	}
+	body := NewTag("body")
+	table, err := TableFromSlice(body, products, &TableOptions{ID: "products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	table.AddStyle("border", "1px solid black")
+	s := body.String()
+	for _, expected := range []string{
+		"<th scope=\"col\">Product name</th>",
+		"<td>&lt;Widget&gt;</td>",
+		"<td>3.50</td>",
+		"<td>2024-01-02</td>",
+		"<td>&nbsp;</td>",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in the table:\n%s", expected, s)
+		}
+	}
+	if strings.Contains(s, "hidden") {
+		t.Errorf("Skipped and unexported fields must not be rendered:\n%s", s)
+	}
+	if table.FindChildByName("tbody").CountChildren() != 2 {
+		t.Error("Expected two rows in the table body")
+	}
+	if !strings.Contains(table.GetCSS(), "#products") {
+		t.Error("Expected the table to be styleable with AddStyle")
+	}
+
+	// Only some columns, in the given order
+	table, err = TableFromSlice(NewTag("body"), []*testProduct{&products[1]}, &TableOptions{Columns: []string{"Stock", "Name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th := table.FindChildByName("th"); th.GetContent() != "In stock" {
+		t.Errorf("Expected the first column to be \"In stock\", got %q", th.GetContent())
+	}
+
+	if _, err := TableFromSlice(body, 42, nil); err == nil {
+		t.Error("Expected an error when not given a slice of structs")
+	}
+}
+
+func TestFormFromStruct(t *testing.T) {
+	p := testProduct{Name: "Widget", Price: 3.5, Active: true}
+	body := NewTag("body")
+	if _, err := FormFromStruct(body, &p); err != nil {
+		t.Fatal(err)
+	}
+	s := body.String()
+	for _, expected := range []string{
+		"name=\"price\"",
+		"type=\"number\"",
+		"step=\"any\"",
+		"type=\"checkbox\"",
+		"type=\"date\"",
+		"type=\"email\"",
+		"value=\"Widget\"",
+	} {
+		if !strings.Contains(s, expected) {
+			t.Errorf("Expected %q in the form:\n%s", expected, s)
+		}
+	}
+
+	// Round trip: bind submitted values and decode them into a struct
+	form, err := NewFormFromStruct("/", "POST", &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{"name": {"Gadget"}, "price": {"12.25"}, "stock": {"3"}, "added": {"2024-05-06"}}
+	req := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := form.Bind(req); err != nil {
+		t.Fatalf("%v: %v", err, form.Errors())
+	}
+	var q testProduct
+	if err := form.Decode(&q); err != nil {
+		t.Fatal(err)
+	}
+	if q.Name != "Gadget" || q.Price != 12.25 || q.Stock != 3 || q.Active || q.Added.Day() != 6 {
+		t.Errorf("Unexpected decoded struct: %+v", q)
+	}
+}
+
+type testOptional struct {
+	Count   *int
+	Note    *string
+	Done    *bool
+	Due     *time.Time
+	Comment any
+}
+
+func TestTableFromSliceNil(t *testing.T) {
+	n := 3
+	body := NewTag("body")
+	if _, err := TableFromSlice(body, []testOptional{{}, {Count: &n, Comment: "ok"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	s := body.String()
+	if strings.Contains(s, "nil") {
+		t.Errorf("Expected nil pointers and interfaces to be rendered as empty cells:\n%s", s)
+	}
+	if strings.Count(s, "<td>&nbsp;</td>") != 8 || !strings.Contains(s, "<td>3</td>") || !strings.Contains(s, "<td>ok</td>") {
+		t.Errorf("Unexpected table:\n%s", s)
+	}
+}
+
+func TestDecodePointerFields(t *testing.T) {
+	form, err := NewFormFromStruct("/", "POST", &testOptional{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := url.Values{"count": {"42"}, "note": {"hello"}, "done": {"on"}}
+	req := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := form.Bind(req); err != nil {
+		t.Fatalf("%v: %v", err, form.Errors())
+	}
+	x := 7
+	v := testOptional{Due: &time.Time{}, Count: &x}
+	if err := form.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Count == nil || *v.Count != 42 || v.Note == nil || *v.Note != "hello" || v.Done == nil || !*v.Done {
+		t.Errorf("Expected the pointer fields to be set: %+v", v)
+	}
+	if v.Due != nil {
+		t.Errorf("Expected an empty date to leave the pointer unset, got %v", v.Due)
+	}
+	if x != 7 {
+		t.Error("Expected a new value to be allocated instead of writing through the old pointer")
+	}
+	form.Field("count").Value = "many"
+	if err := form.Decode(&v); err == nil {
+		t.Error("Expected an error for an invalid number")
+	}
+}
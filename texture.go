@@ -0,0 +1,208 @@
+package onthefly
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+
+	"github.com/xyproto/onthefly/js"
+)
+
+// Side is the side of the faces that a material is rendered on
+type Side int
+
+// The sides that a material can be rendered on
+const (
+	FrontSide Side = iota
+	BackSide
+	DoubleSide
+)
+
+// String returns the name of the Three.JS constant for the side, like "DoubleSide"
+func (side Side) String() string {
+	switch side {
+	case BackSide:
+		return "BackSide"
+	case DoubleSide:
+		return "DoubleSide"
+	default:
+		return "FrontSide"
+	}
+}
+
+// MaterialOptions are optional settings for NewMaterial, NewLambertMaterial,
+// NewPhongMaterial and NewStandardMaterial. Settings with the zero value are not used.
+type MaterialOptions struct {
+	Map          *Texture // the diffuse color map
+	NormalMap    *Texture
+	RoughnessMap *Texture // only for standard materials
+	MetalnessMap *Texture // only for standard materials
+	EmissiveMap  *Texture
+	Emissive     string  // the emissive color, white if only EmissiveMap is given
+	Transparent  bool    // use Opacity
+	Opacity      float64 // from 0 to 1, used if Transparent is true
+	Side         Side
+	Wireframe    bool
+	FlatShading  bool
+}
+
+// materialParams adds the options to the parameters of a new material of the given
+// Three.JS class. The returned code declares the textures that are not from the given scene.
+func (o *MaterialOptions) materialParams(s *Scene, class string, params js.Object) (string, error) {
+	basic := class == "MeshBasicMaterial"
+	if basic && (o.NormalMap != nil || o.EmissiveMap != nil || o.Emissive != "" || o.FlatShading) {
+		return "", errors.New("basic materials do not support normal maps, emissive colors or flat shading")
+	}
+	if class != "MeshStandardMaterial" && (o.RoughnessMap != nil || o.MetalnessMap != nil) {
+		return "", errors.New("only standard materials support roughness and metalness maps")
+	}
+	if o.Transparent && (o.Opacity < 0 || o.Opacity > 1) {
+		return "", fmt.Errorf("the opacity must be from 0 to 1: %v", o.Opacity)
+	}
+	var code string
+	maps := []struct {
+		name    string
+		texture *Texture
+	}{
+		{"map", o.Map},
+		{"normalMap", o.NormalMap},
+		{"roughnessMap", o.RoughnessMap},
+		{"metalnessMap", o.MetalnessMap},
+		{"emissiveMap", o.EmissiveMap},
+	}
+	for _, m := range maps {
+		if m.texture == nil {
+			continue
+		}
+		if !s.owns((*Element)(m.texture)) {
+			code += m.texture.JS
+		}
+		params[m.name] = js.Ident(m.texture.ID)
+	}
+	switch {
+	case o.Emissive != "":
+		c, err := threeColor(o.Emissive)
+		if err != nil {
+			return "", err
+		}
+		params["emissive"] = c
+	case o.EmissiveMap != nil:
+		params["emissive"] = js.Raw("0xffffff")
+	}
+	if o.Transparent {
+		params["transparent"] = js.Value(true)
+		params["opacity"] = js.Value(o.Opacity)
+	}
+	if o.Side != FrontSide {
+		params["side"] = threeClass(o.Side.String())
+	}
+	if o.Wireframe {
+		params["wireframe"] = js.Value(true)
+	}
+	if o.FlatShading {
+		params["flatShading"] = js.Value(true)
+	}
+	return code, nil
+}
+
+// newMaterial creates a material of the given Three.JS class, with the given color and options
+func (s *Scene) newMaterial(class, color string, opts []MaterialOptions) (*Material, error) {
+	c, err := threeColor(color)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts) > 1 {
+		return nil, errors.New("only one MaterialOptions can be given")
+	}
+	params := js.Object{"color": c}
+	var code string
+	if len(opts) == 1 {
+		if code, err = opts[0].materialParams(s, class, params); err != nil {
+			return nil, err
+		}
+	}
+	id := s.nextID(materialPrefix)
+	return s.addMaterial(&Material{ID: id, JS: code + newThree(id, class, params)}), nil
+}
+
+// NewTexture creates a texture that is loaded from the given URL
+func (s *Scene) NewTexture(url string) *Texture {
+	id := s.nextID(texturePrefix)
+	loader := js.New(threeClass("TextureLoader"))
+	t := &Texture{ID: id, JS: js.Var(id, js.Method(loader, "load", js.String(url))).String()}
+	s.register((*Element)(t))
+	if !s.global {
+		s.mu.Lock()
+		s.textures = append(s.textures, t)
+		s.mu.Unlock()
+	}
+	return t
+}
+
+// encodePNG encodes the given image as PNG
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewTextureFromImage creates a texture from the given image, which is included in
+// the script as a PNG data URL. For large images, see ServeTexture.
+func (s *Scene) NewTextureFromImage(img image.Image) (*Texture, error) {
+	data, err := encodePNG(img)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewTexture("data:image/png;base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// ServeTexture serves the given image as a PNG at the given URL path,
+// and creates a texture that is loaded from there
+func (s *Scene) ServeTexture(mux *http.ServeMux, path string, img image.Image) (*Texture, error) {
+	data, err := encodePNG(img)
+	if err != nil {
+		return nil, err
+	}
+	mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data)
+	})
+	return s.NewTexture(path), nil
+}
+
+// Textures returns the textures that have been created by the scene
+func (s *Scene) Textures() []*Texture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Texture(nil), s.textures...)
+}
+
+// SetRepeat makes the texture repeat the given number of times in each direction
+func (t *Texture) SetRepeat(u, v float64) {
+	t.JS += js.Assign(js.Ident(t.ID+".wrapS"), threeClass("RepeatWrapping")).String()
+	t.JS += js.Assign(js.Ident(t.ID+".wrapT"), threeClass("RepeatWrapping")).String()
+	t.JS += call(t.ID+".repeat", "set", nums(u, v)...)
+}
+
+// NewTexture creates a texture that is loaded from the given URL
+func NewTexture(url string) *Texture {
+	return defaultScene.NewTexture(url)
+}
+
+// NewTextureFromImage creates a texture from the given image, which is included in
+// the script as a PNG data URL. For large images, see ServeTexture.
+func NewTextureFromImage(img image.Image) (*Texture, error) {
+	return defaultScene.NewTextureFromImage(img)
+}
+
+// ServeTexture serves the given image as a PNG at the given URL path,
+// and creates a texture that is loaded from there
+func ServeTexture(mux *http.ServeMux, path string, img image.Image) (*Texture, error) {
+	return defaultScene.ServeTexture(mux, path, img)
+}
@@ -0,0 +1,101 @@
+package onthefly
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaterialOptions(t *testing.T) {
+	scene := NewScene()
+	diffuse := scene.NewTexture("/img/bricks.png")
+	diffuse.SetRepeat(4, 2)
+	normal := scene.NewTexture("/img/bricks_normal.png")
+	material, err := scene.NewStandardMaterial("#ffffff", MaterialOptions{
+		Map:         diffuse,
+		NormalMap:   normal,
+		Transparent: true,
+		Opacity:     0.5,
+		Side:        DoubleSide,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `var ma0 = new THREE.MeshStandardMaterial({color: "#ffffff", map: tex0, normalMap: tex1, opacity: 0.5, side: THREE.DoubleSide, transparent: true});`
+	if material.JS != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, material.JS)
+	}
+	s := scene.String()
+	if !strings.HasPrefix(s, `var scene = new THREE.Scene();var tex0 = new THREE.TextureLoader().load("/img/bricks.png");tex0.wrapS = THREE.RepeatWrapping;`) ||
+		!strings.HasSuffix(s, expected) || len(scene.Textures()) != 2 {
+		t.Errorf("Expected the textures to be declared before the material:\n%s", s)
+	}
+
+	// Textures from other scenes are declared together with the material
+	foreign := NewTexture("/img/glow.png")
+	material, err = scene.NewPhongMaterial("0x000000", MaterialOptions{EmissiveMap: foreign, Wireframe: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(material.JS, foreign.JS) || !strings.Contains(material.JS, "emissive: 0xffffff, emissiveMap: "+foreign.ID+", wireframe: true") {
+		t.Errorf("Unexpected material: %s", material.JS)
+	}
+
+	for _, opts := range []MaterialOptions{
+		{FlatShading: true},
+		{RoughnessMap: diffuse},
+		{Emissive: "not a color"},
+	} {
+		if _, err := scene.NewMaterial("red", opts); err == nil {
+			t.Errorf("Expected an error for a basic material with %+v", opts)
+		}
+	}
+	if _, err := scene.NewLambertMaterial("red", MaterialOptions{Transparent: true, Opacity: 2}); err == nil {
+		t.Error("Expected an error for an opacity above 1")
+	}
+	if _, err := scene.NewLambertMaterial("red", MaterialOptions{}, MaterialOptions{}); err == nil {
+		t.Error("Expected an error for more than one MaterialOptions")
+	}
+}
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	return img
+}
+
+func TestNewTextureFromImage(t *testing.T) {
+	texture, err := NewTextureFromImage(testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(texture.JS, `.load("data:image/png;base64,iVBORw0KGgo`) {
+		t.Errorf("Expected a PNG data URL: %s", texture.JS)
+	}
+}
+
+func TestServeTexture(t *testing.T) {
+	mux := http.NewServeMux()
+	texture, err := NewScene().ServeTexture(mux, "/textures/red.png", testImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texture.JS != `var tex0 = new THREE.TextureLoader().load("/textures/red.png");` {
+		t.Errorf("Unexpected texture: %s", texture.JS)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/textures/red.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != "image/png" || !strings.HasPrefix(string(data), "\x89PNG") {
+		t.Errorf("Expected a PNG image, got %q", resp.Header.Get("Content-Type"))
+	}
+}
@@ -1,39 +1,112 @@
 package onthefly
 
 import (
+	"errors"
 	"fmt"
-	"log"
-)
+	"regexp"
+	"slices"
+	"strings"
 
-// For generating IDs
-var (
-	geometryCounter = 0
-	materialCounter = 0
-	meshCounter     = 0
-	cameraCounter   = 0
-	lightCounter    = 0
-	rendererCounter = 0
+	"github.com/xyproto/onthefly/js"
 )
 
 // Unique prefixes when generating IDs
 const (
-	geometryPrefix = "g"
-	materialPrefix = "ma"
-	meshPrefix     = "m"
-	cameraPrefix   = "cam"
-	lightPrefix    = "light"
-	rendererPrefix = "renderer"
+	geometryPrefix  = "g"
+	materialPrefix  = "ma"
+	meshPrefix      = "m"
+	cameraPrefix    = "cam"
+	lightPrefix     = "light"
+	rendererPrefix  = "renderer"
+	groupPrefix     = "grp"
+	texturePrefix   = "tex"
+	modelPrefix     = "model"
+	controlsPrefix  = "controls"
+	animationPrefix = "anim"
+)
+
+// threeClass returns a reference to the given Three.JS class, like THREE.Mesh
+func threeClass(class string) js.Expr {
+	return js.Ident("THREE." + class)
+}
+
+// newThree returns a statement that declares a variable with a new instance of the given Three.JS class
+func newThree(id, class string, args ...js.Expr) string {
+	return js.Var(id, js.New(threeClass(class), args...)).String()
+}
+
+// nums returns the given numbers as JavaScript expressions
+func nums(xs ...float64) []js.Expr {
+	exprs := make([]js.Expr, len(xs))
+	for i, x := range xs {
+		exprs[i] = js.Value(x)
+	}
+	return exprs
+}
+
+var (
+	// numericColorPattern matches colors that are numbers, like 0xff0000 or 16711680
+	numericColorPattern = regexp.MustCompile(`^(0[xX][0-9a-fA-F]{1,6}|[0-9]{1,8})$`)
+	// cssColorPattern matches colors that Three.JS can parse from a string, like #ff0000, red or rgb(255, 0, 0)
+	cssColorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]+|(rgb|hsl)a?\([0-9., %]+\))$`)
 )
 
+// threeColor checks the given color and returns it as a JavaScript expression.
+// The color can be a number, like 0xff0000, or a CSS color like #ff0000, red or rgb(255, 0, 0),
+// with or without quotes.
+func threeColor(color string) (js.Expr, error) {
+	color = strings.TrimSpace(color)
+	if numericColorPattern.MatchString(color) {
+		return js.Raw(color), nil
+	}
+	if len(color) >= 2 && (color[0] == '"' || color[0] == '\'') && color[len(color)-1] == color[0] {
+		color = color[1 : len(color)-1]
+	}
+	if cssColorPattern.MatchString(color) {
+		return js.String(color), nil
+	}
+	return nil, fmt.Errorf("invalid color: %q", color)
+}
+
+// checkSegments returns an error if any of the given segment counts are negative
+func checkSegments(segments ...int) error {
+	for _, n := range segments {
+		if n < 0 {
+			return fmt.Errorf("the number of segments can not be negative: %d", n)
+		}
+	}
+	return nil
+}
+
+// call returns a statement that calls a method on the variable with the given ID
+func call(id, method string, args ...js.Expr) string {
+	return js.ExprStmt(js.Method(js.Ident(id), method, args...)).String()
+}
+
 type (
 	// Element represents Three.JS elements, like a mesh or material
 	Element struct {
-		ID string // name of the variable
-		JS string // javascript code for creating the element
+		ID       string // name of the variable
+		JS       string // javascript code for creating the element
+		children []*Element
+		pickable bool   // has click or hover handlers, see Mesh.OnClick
+		camera   string // the camera that is moved, for Controls
+	}
+	// Object is a Three.JS object that can be added to a scene or to another object,
+	// like a Mesh, Group, Light or Camera
+	Object interface {
+		Element() *Element
 	}
 	// RenderFunc represents the Three.JS render function, where head and tail are standard
 	RenderFunc struct {
 		head, mid, tail string
+		sceneID         string // the variables that the render function uses
+		rendererID      string
+		cameraID        string
+		setup           []string // declared before the render function, for animations and picking
+		updates         []string // run first, with the seconds since the previous frame in delta
+		added           []string // the IDs of the controls and the keys of the animations that are run
+		animations      []*Animation
 	}
 	// Geometry represents a Three.JS geometry
 	Geometry Element
@@ -47,9 +120,16 @@ type (
 	Light Element
 	// Renderer represents a Three.JS renderer
 	Renderer Element
+	// Texture represents a Three.JS texture
+	Texture Element
+	// Group represents a Three.JS group, for transforming several objects together
+	Group Element
 )
 
-// NewThreeJS creates a HTML5 page that links with Three.JS and sets up a scene
+// NewThreeJS creates a HTML5 page that links with Three.JS and sets up a scene.
+// The elements that are created with the package-level functions, like NewMesh, share
+// one counter for the whole process, so their IDs depend on what was created before.
+// Use NewScene for output that does not depend on the order.
 func NewThreeJS(args ...string) (*Page, *Tag) {
 	title := "Untitled"
 	if len(args) > 0 {
@@ -97,66 +177,61 @@ func NewThreeJSWithGiven(titleText, js string) (*Page, *Tag) {
 	return page, script
 }
 
-// AddCamera adds a camera with default settings
-func (three *Tag) AddCamera() {
+// AddCamera adds a camera with default settings, in the "camera" variable
+func (three *Tag) AddCamera() *Camera {
 	three.AddContent("var camera = new THREE.PerspectiveCamera(75, window.innerWidth/window.innerHeight, 0.1, 1000);")
+	return &Camera{ID: "camera"}
 }
 
 // NewPerspectiveCamera creates a new perspective camera with custom parameters
 func NewPerspectiveCamera(fov, aspect, near, far float64) *Camera {
-	id := fmt.Sprintf("%s%d", cameraPrefix, cameraCounter)
-	cameraCounter++
-	js := fmt.Sprintf("var %s = new THREE.PerspectiveCamera(%g, %g, %g, %g);", id, fov, aspect, near, far)
-	return &Camera{id, js}
+	return defaultScene.NewPerspectiveCamera(fov, aspect, near, far)
 }
 
 // NewOrthographicCamera creates a new orthographic camera
 func NewOrthographicCamera(left, right, top, bottom, near, far float64) *Camera {
-	id := fmt.Sprintf("%s%d", cameraPrefix, cameraCounter)
-	cameraCounter++
-	js := fmt.Sprintf("var %s = new THREE.OrthographicCamera(%g, %g, %g, %g, %g, %g);", id, left, right, top, bottom, near, far)
-	return &Camera{id, js}
+	return defaultScene.NewOrthographicCamera(left, right, top, bottom, near, far)
 }
 
-// AddRenderer adds a WebGL renderer with default settings
-func (three *Tag) AddRenderer() {
+// AddRenderer adds a WebGL renderer with default settings, in the "renderer" variable
+func (three *Tag) AddRenderer() *Renderer {
 	three.AddContent("var renderer = new THREE.WebGLRenderer();")
 	three.AddContent("renderer.setSize(window.innerWidth, window.innerHeight);")
 	three.AddContent("document.body.appendChild(renderer.domElement);")
+	return &Renderer{ID: "renderer"}
 }
 
 // NewWebGLRenderer creates a new WebGL renderer with custom options
 func NewWebGLRenderer(antialias bool) *Renderer {
-	id := fmt.Sprintf("%s%d", rendererPrefix, rendererCounter)
-	rendererCounter++
-	js := fmt.Sprintf("var %s = new THREE.WebGLRenderer({antialias: %t});", id, antialias)
-	js += fmt.Sprintf("%s.setSize(window.innerWidth, window.innerHeight);", id)
-	js += fmt.Sprintf("document.body.appendChild(%s.domElement);", id)
-	return &Renderer{id, js}
+	return defaultScene.NewWebGLRenderer(antialias)
 }
 
 // SetShadowMap enables shadow mapping for a renderer
 func (r *Renderer) SetShadowMap(enabled bool) {
-	r.JS += fmt.Sprintf("%s.shadowMap.enabled = %t;", r.ID, enabled)
-	r.JS += fmt.Sprintf("%s.shadowMap.type = THREE.PCFSoftShadowMap;", r.ID)
+	r.JS += js.Assign(js.Ident(r.ID+".shadowMap.enabled"), js.Value(enabled)).String()
+	r.JS += js.Assign(js.Ident(r.ID+".shadowMap.type"), threeClass("PCFSoftShadowMap")).String()
 }
 
-// AddToScene adds a mesh to the current scene
+// AddToScene adds a mesh, and the objects that have been added to it, to the current scene
 func (three *Tag) AddToScene(mesh *Mesh) {
-	three.AddContent(mesh.JS)
-	three.AddContent("scene.add(" + mesh.ID + ");")
+	three.AddElementToScene((*Element)(mesh))
 }
 
-// AddElementToScene adds any Three.js element to the current scene
+// AddElementToScene adds any Three.js element, and the objects that have been added to it,
+// to the current scene
 func (three *Tag) AddElementToScene(element *Element) {
-	three.AddContent(element.JS)
-	three.AddContent("scene.add(" + element.ID + ");")
+	three.AddContent(element.treeJS(make(map[*Element]bool)))
+	three.AddContent(call("scene", "add", js.Ident(element.ID)))
 }
 
 // AddLightToScene adds a light to the current scene
 func (three *Tag) AddLightToScene(light *Light) {
-	three.AddContent(light.JS)
-	three.AddContent("scene.add(" + light.ID + ");")
+	three.AddElementToScene((*Element)(light))
+}
+
+// AddGroupToScene adds a group, and the objects that have been added to it, to the current scene
+func (three *Tag) AddGroupToScene(group *Group) {
+	three.AddElementToScene((*Element)(group))
 }
 
 // AddCameraToScene adds a camera element to the scene (for helper visualization)
@@ -166,107 +241,186 @@ func (three *Tag) AddCameraToScene(camera *Camera) {
 
 // NewMesh creates a new mesh, given geometry and material.
 // The geometry and material will be instanciated together with the mesh.
+// Use a Scene for declaring geometry and materials only once.
 func NewMesh(geometry *Geometry, material *Material) *Mesh {
-	id := fmt.Sprintf("%s%d", meshPrefix, meshCounter)
-	meshCounter++
-	js := geometry.JS + material.JS
-	js += "var " + id + " = new THREE.Mesh(" + geometry.ID + ", " + material.ID + ");"
-	return &Mesh{id, js}
+	return defaultScene.NewMesh(geometry, material)
+}
+
+// CameraPos sets the position of the camera that was added with AddCamera.
+// Axis must be "x", "y", or "z".
+func (three *Tag) CameraPos(axis string, value float64) error {
+	return three.SetCameraPos(&Camera{ID: "camera"}, axis, value)
 }
 
-// CameraPos sets the camera position. Axis must be "x", "y", or "z".
-func (three *Tag) CameraPos(axis string, value float64) {
+// SetCameraPos sets the position of the given camera. Axis must be "x", "y", or "z".
+func (three *Tag) SetCameraPos(camera *Camera, axis string, value float64) error {
 	if (axis != "x") && (axis != "y") && (axis != "z") {
-		log.Fatalln("camera axis must be x, y or z")
+		return errors.New("camera axis must be x, y or z")
+	}
+	three.AddContent(js.Assign(js.Ident(camera.ID+".position."+axis), js.Value(value)).String())
+	return nil
+}
+
+// NewGroup creates a new, empty group. Objects that are added to the group are
+// transformed together with it.
+func NewGroup() *Group {
+	return defaultScene.NewGroup()
+}
+
+// Element returns the element itself, so that an Element is also an Object
+func (e *Element) Element() *Element {
+	return e
+}
+
+// Element returns the mesh as an Element
+func (m *Mesh) Element() *Element {
+	return (*Element)(m)
+}
+
+// Element returns the group as an Element
+func (g *Group) Element() *Element {
+	return (*Element)(g)
+}
+
+// Element returns the light as an Element
+func (l *Light) Element() *Element {
+	return (*Element)(l)
+}
+
+// Element returns the camera as an Element
+func (c *Camera) Element() *Element {
+	return (*Element)(c)
+}
+
+// Add adds the given objects as children of this element, so that they are
+// transformed together with it. The children are declared before they are added,
+// also when they are created after the parent.
+func (e *Element) Add(children ...Object) {
+	for _, child := range children {
+		if c := child.Element(); c != e {
+			e.children = append(e.children, c)
+		}
 	}
-	three.AddContent(fmt.Sprintf("camera.position.%s = %g;", axis, value))
+}
+
+// Children returns the objects that have been added to this element
+func (e *Element) Children() []*Element {
+	return append([]*Element(nil), e.children...)
+}
+
+// treeJS returns the JavaScript code for the element, followed by the code for its
+// children and for adding them to the element. Elements in declared are skipped.
+func (e *Element) treeJS(declared map[*Element]bool) string {
+	if declared[e] {
+		return ""
+	}
+	declared[e] = true
+	code := e.JS
+	for _, child := range e.children {
+		code += child.treeJS(declared)
+		code += call(e.ID, "add", js.Ident(child.ID))
+	}
+	return code
+}
+
+// Add adds the given objects to the group
+func (g *Group) Add(children ...Object) {
+	(*Element)(g).Add(children...)
+}
+
+// SetPosition sets the position of the group
+func (g *Group) SetPosition(x, y, z float64) {
+	(*Element)(g).SetPosition(x, y, z)
+}
+
+// SetRotation sets the rotation of the group
+func (g *Group) SetRotation(x, y, z float64) {
+	(*Element)(g).SetRotation(x, y, z)
+}
+
+// SetScale sets the scale of the group
+func (g *Group) SetScale(x, y, z float64) {
+	(*Element)(g).SetScale(x, y, z)
+}
+
+// Add adds the given objects to the mesh, so that they follow the mesh
+func (m *Mesh) Add(children ...Object) {
+	(*Element)(m).Add(children...)
+}
+
+// SetPosition sets the position of the mesh
+func (m *Mesh) SetPosition(x, y, z float64) {
+	(*Element)(m).SetPosition(x, y, z)
+}
+
+// SetRotation sets the rotation of the mesh
+func (m *Mesh) SetRotation(x, y, z float64) {
+	(*Element)(m).SetRotation(x, y, z)
+}
+
+// SetScale sets the scale of the mesh
+func (m *Mesh) SetScale(x, y, z float64) {
+	(*Element)(m).SetScale(x, y, z)
 }
 
 // SetPosition sets the position of any Three.js object
 func (e *Element) SetPosition(x, y, z float64) {
-	e.JS += fmt.Sprintf("%s.position.set(%g, %g, %g);", e.ID, x, y, z)
+	e.JS += call(e.ID+".position", "set", nums(x, y, z)...)
 }
 
 // SetRotation sets the rotation of any Three.js object
 func (e *Element) SetRotation(x, y, z float64) {
-	e.JS += fmt.Sprintf("%s.rotation.set(%g, %g, %g);", e.ID, x, y, z)
+	e.JS += call(e.ID+".rotation", "set", nums(x, y, z)...)
 }
 
 // SetScale sets the scale of any Three.js object
 func (e *Element) SetScale(x, y, z float64) {
-	e.JS += fmt.Sprintf("%s.scale.set(%g, %g, %g);", e.ID, x, y, z)
+	e.JS += call(e.ID+".scale", "set", nums(x, y, z)...)
 }
 
 // NewMaterial creates a very simple type of material
-func NewMaterial(color string) *Material {
-	id := fmt.Sprintf("%s%d", materialPrefix, materialCounter)
-	materialCounter++
-	js := "var " + id + " = new THREE.MeshBasicMaterial({color: " + color + "});"
-	return &Material{id, js}
+func NewMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return defaultScene.NewMaterial(color, opts...)
 }
 
 // NewNormalMaterial creates a material which reflects the normals of the geometry
 func NewNormalMaterial() *Material {
-	id := fmt.Sprintf("%s%d", materialPrefix, materialCounter)
-	materialCounter++
-	js := "var " + id + " = new THREE.MeshNormalMaterial();"
-	return &Material{id, js}
+	return defaultScene.NewNormalMaterial()
 }
 
 // NewLambertMaterial creates a Lambert material (responds to lighting)
-func NewLambertMaterial(color string) *Material {
-	id := fmt.Sprintf("%s%d", materialPrefix, materialCounter)
-	materialCounter++
-	js := fmt.Sprintf("var %s = new THREE.MeshLambertMaterial({color: %s});", id, color)
-	return &Material{id, js}
+func NewLambertMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return defaultScene.NewLambertMaterial(color, opts...)
 }
 
 // NewPhongMaterial creates a Phong material (supports shiny surfaces)
-func NewPhongMaterial(color string) *Material {
-	id := fmt.Sprintf("%s%d", materialPrefix, materialCounter)
-	materialCounter++
-	js := fmt.Sprintf("var %s = new THREE.MeshPhongMaterial({color: %s});", id, color)
-	return &Material{id, js}
+func NewPhongMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return defaultScene.NewPhongMaterial(color, opts...)
 }
 
 // NewStandardMaterial creates a standard material (physically based)
-func NewStandardMaterial(color string) *Material {
-	id := fmt.Sprintf("%s%d", materialPrefix, materialCounter)
-	materialCounter++
-	js := fmt.Sprintf("var %s = new THREE.MeshStandardMaterial({color: %s});", id, color)
-	return &Material{id, js}
+func NewStandardMaterial(color string, opts ...MaterialOptions) (*Material, error) {
+	return defaultScene.NewStandardMaterial(color, opts...)
 }
 
 // NewBoxGeometry creates geometry for a box
 func NewBoxGeometry(w, h, d float64) *Geometry {
-	id := fmt.Sprintf("%s%d", geometryPrefix, geometryCounter)
-	geometryCounter++
-	js := fmt.Sprintf("var %s = new THREE.BoxGeometry(%g, %g, %g);", id, w, h, d)
-	return &Geometry{id, js}
+	return defaultScene.NewBoxGeometry(w, h, d)
 }
 
 // NewSphereGeometry creates geometry for a sphere
-func NewSphereGeometry(radius float64, widthSegments, heightSegments int) *Geometry {
-	id := fmt.Sprintf("%s%d", geometryPrefix, geometryCounter)
-	geometryCounter++
-	js := fmt.Sprintf("var %s = new THREE.SphereGeometry(%g, %d, %d);", id, radius, widthSegments, heightSegments)
-	return &Geometry{id, js}
+func NewSphereGeometry(radius float64, widthSegments, heightSegments int) (*Geometry, error) {
+	return defaultScene.NewSphereGeometry(radius, widthSegments, heightSegments)
 }
 
 // NewPlaneGeometry creates geometry for a plane
 func NewPlaneGeometry(width, height float64) *Geometry {
-	id := fmt.Sprintf("%s%d", geometryPrefix, geometryCounter)
-	geometryCounter++
-	js := fmt.Sprintf("var %s = new THREE.PlaneGeometry(%g, %g);", id, width, height)
-	return &Geometry{id, js}
+	return defaultScene.NewPlaneGeometry(width, height)
 }
 
 // NewCylinderGeometry creates geometry for a cylinder
-func NewCylinderGeometry(radiusTop, radiusBottom, height float64, radialSegments int) *Geometry {
-	id := fmt.Sprintf("%s%d", geometryPrefix, geometryCounter)
-	geometryCounter++
-	js := fmt.Sprintf("var %s = new THREE.CylinderGeometry(%g, %g, %g, %d);", id, radiusTop, radiusBottom, height, radialSegments)
-	return &Geometry{id, js}
+func NewCylinderGeometry(radiusTop, radiusBottom, height float64, radialSegments int) (*Geometry, error) {
+	return defaultScene.NewCylinderGeometry(radiusTop, radiusBottom, height, radialSegments)
 }
 
 // AddTestCube adds a test cube to the scene
@@ -279,34 +433,73 @@ func (three *Tag) AddTestCube() *Mesh {
 }
 
 // NewAmbientLight creates an ambient light that illuminates all objects equally
-func NewAmbientLight(color string, intensity float64) *Light {
-	id := fmt.Sprintf("%s%d", lightPrefix, lightCounter)
-	lightCounter++
-	js := fmt.Sprintf("var %s = new THREE.AmbientLight(%s, %g);", id, color, intensity)
-	return &Light{id, js}
+func NewAmbientLight(color string, intensity float64) (*Light, error) {
+	return defaultScene.NewAmbientLight(color, intensity)
 }
 
 // NewDirectionalLight creates a directional light (like sunlight)
-func NewDirectionalLight(color string, intensity float64) *Light {
-	id := fmt.Sprintf("%s%d", lightPrefix, lightCounter)
-	lightCounter++
-	js := fmt.Sprintf("var %s = new THREE.DirectionalLight(%s, %g);", id, color, intensity)
-	return &Light{id, js}
+func NewDirectionalLight(color string, intensity float64) (*Light, error) {
+	return defaultScene.NewDirectionalLight(color, intensity)
 }
 
 // NewPointLight creates a point light (like a light bulb)
-func NewPointLight(color string, intensity, distance float64) *Light {
-	id := fmt.Sprintf("%s%d", lightPrefix, lightCounter)
-	lightCounter++
-	js := fmt.Sprintf("var %s = new THREE.PointLight(%s, %g, %g);", id, color, intensity, distance)
-	return &Light{id, js}
+func NewPointLight(color string, intensity, distance float64) (*Light, error) {
+	return defaultScene.NewPointLight(color, intensity, distance)
 }
 
-// NewRenderFunction creates a new render function, which is called at every animation frame
+// NewRenderFunction creates a new render function, which is called at every animation frame.
+// It renders the "scene" variable with the renderer and camera that were added with
+// AddRenderer and AddCamera. See also NewRenderFunctionFor.
 func NewRenderFunction() *RenderFunc {
+	return newRenderFunction("scene", "renderer", "camera")
+}
+
+// NewRenderFunctionFor creates a new render function, which is called at every animation frame.
+// It renders the "scene" variable with the given renderer and camera.
+func NewRenderFunctionFor(renderer *Renderer, camera *Camera) *RenderFunc {
+	return newRenderFunction("scene", renderer.ID, camera.ID)
+}
+
+// newRenderFunction creates a render function that renders the given scene with the given renderer and camera
+func newRenderFunction(sceneID, rendererID, cameraID string) *RenderFunc {
 	head := "var render = function() { requestAnimationFrame(render);"
-	tail := "renderer.render(scene, camera); };"
-	return &RenderFunc{head, "", tail}
+	tail := call(rendererID, "render", js.Ident(sceneID), js.Ident(cameraID)) + " };"
+	return &RenderFunc{head: head, tail: tail, sceneID: sceneID, rendererID: rendererID, cameraID: cameraID}
+}
+
+// String returns the JavaScript code for the render function
+func (r *RenderFunc) String() string {
+	setup := strings.Join(r.setup, "")
+	if len(r.updates) == 0 {
+		return setup + r.head + r.mid + r.tail
+	}
+	clock := newThree("renderClock", "Clock")
+	return clock + setup + r.head + "var delta = renderClock.getDelta();" + strings.Join(r.updates, "") + r.mid + r.tail
+}
+
+// AddUpdate adds statements that are run at the start of every frame, before the code that
+// has been added with AddJS. The number of seconds since the previous frame is in the
+// "delta" variable, which can also be used by the code that is added with AddJS.
+func (r *RenderFunc) AddUpdate(stmts ...js.Stmt) {
+	r.updates = append(r.updates, js.Join(stmts...))
+}
+
+// markAdded checks if the given key has been added to the render function, and adds it if not
+func (r *RenderFunc) markAdded(key string) bool {
+	if slices.Contains(r.added, key) {
+		return true
+	}
+	r.added = append(r.added, key)
+	return false
+}
+
+// addSetup adds code that is declared before the render function, unless it has already been added
+func (r *RenderFunc) addSetup(codes ...string) {
+	for _, code := range codes {
+		if !slices.Contains(r.setup, code) {
+			r.setup = append(r.setup, code)
+		}
+	}
 }
 
 // AddJS adds javascript code to the body of a render function
@@ -314,22 +507,48 @@ func (r *RenderFunc) AddJS(s string) {
 	r.mid += s
 }
 
+// AddStmt adds statements to the body of a render function
+func (r *RenderFunc) AddStmt(stmts ...js.Stmt) {
+	r.mid += js.Join(stmts...)
+}
+
+// AddStmt adds statements to a Three.JS script tag
+func (three *Tag) AddStmt(stmts ...js.Stmt) {
+	three.AddContent(js.Join(stmts...))
+}
+
+// Ref returns a reference to the variable of the element, for use with the js package
+func (e *Element) Ref() js.Expr {
+	return js.Ident(e.ID)
+}
+
 // AddRenderFunction adds a render function.
 // If call is true, the render function is called at the end of the script.
 func (three *Tag) AddRenderFunction(r *RenderFunc, call bool) {
-	three.AddContent(r.head + r.mid + r.tail)
+	three.AddContent(r.String())
 	if call {
 		three.AddContent("render();")
 	}
 }
 
-// AddWindowResizeHandler adds a window resize handler to keep the renderer responsive
+// resizeHandler returns a statement that keeps the camera and renderer with the given IDs
+// in sync with the size of the window
+func resizeHandler(camera *Camera, renderer *Renderer) js.Stmt {
+	return js.ExprStmt(js.Call(js.Ident("window.addEventListener"), js.String("resize"), js.Fn(
+		js.Assign(js.Ident(camera.ID+".aspect"), js.Raw("window.innerWidth / window.innerHeight")),
+		js.ExprStmt(js.Method(js.Ident(camera.ID), "updateProjectionMatrix")),
+		js.ExprStmt(js.Method(js.Ident(renderer.ID), "setSize", js.Ident("window.innerWidth"), js.Ident("window.innerHeight"))),
+	)))
+}
+
+// AddWindowResizeHandler adds a window resize handler to keep the renderer responsive.
+// See also AddResizeHandler.
 func (three *Tag) AddWindowResizeHandler(cameraID, rendererID string) {
-	resizeJS := fmt.Sprintf(`
-window.addEventListener('resize', function() {
-	%s.aspect = window.innerWidth / window.innerHeight;
-	%s.updateProjectionMatrix();
-	%s.setSize(window.innerWidth, window.innerHeight);
-});`, cameraID, cameraID, rendererID)
-	three.AddContent(resizeJS)
+	three.AddResizeHandler(&Camera{ID: cameraID}, &Renderer{ID: rendererID})
+}
+
+// AddResizeHandler adds a window resize handler that keeps the given camera and renderer
+// in sync with the size of the window
+func (three *Tag) AddResizeHandler(camera *Camera, renderer *Renderer) {
+	three.AddStmt(resizeHandler(camera, renderer))
 }